@@ -1,17 +1,25 @@
 package consistent
 
 import (
+	"context"
+	"encoding/binary"
 	"errors"
-	"log"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net"
 	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ka3de/consistent/pkg/remote"
 )
 
 const (
-	defNReplicas = 20
+	defNReplicas         = 20
+	defReconcileInterval = time.Minute
 )
 
 var (
@@ -21,6 +29,8 @@ var (
 	ErrSrvAlreadyExists = errors.New("server already exists in the ring")
 	// ErrSrvNotExists indicates that the given server is not present in the ring.
 	ErrSrvNotExists = errors.New("server is not present in the ring")
+	// ErrNoAddr indicates that the server has no known network address.
+	ErrNoAddr = errors.New("server has no known address")
 )
 
 type Hash uint32
@@ -30,60 +40,120 @@ type Hasher interface {
 	Hash(key string) Hash
 }
 
+// ringMember holds the information kept for each ring member.
+type ringMember struct {
+	// name is the remote node name of the member,
+	// which can differ from its ring identity.
+	name     string
+	addr     net.Addr
+	weight   float64 // 0 means the default weight
+	draining bool
+	// remote reports whether the member comes from the ring
+	// remote, being the rest of them added by hand with Add.
+	remote bool
+}
+
 // Consistent represents a consistent hashing ring.
 type Consistent struct {
 	mu sync.RWMutex
 
-	members map[string]struct{}
+	members map[string]ringMember
 	ring    map[Hash]string
 	hashes  []Hash
 
+	// epoch is a Lamport clock bumped on every change of the ring
+	// members and advanced to the greater epochs observed, so lookups
+	// can be fenced by the ring state they used across the cluster.
+	epoch uint64
+
 	hasher Hasher
 
 	nReplicas int
 
-	remote remote.Remoter
+	ringConfig map[string]string
+	weights    map[string]float64
+	drains     map[string]bool
+	overrides  map[string]string
+
+	self string
+
+	remoteMu          sync.Mutex
+	remote            remote.Remoter
+	remoteMembers     map[string]remote.Member
+	incarnations      map[string]incarnation
+	filter            MemberFilter
+	identity          Identity
+	logger            *slog.Logger
+	errHandler        func(remote.Event, error)
+	remoteCancel      context.CancelFunc
+	remoteDone        chan struct{}
+	reconcileInterval time.Duration
+	closed            bool
+	stale             atomic.Bool
 }
 
 // NewConsistent creates a new consistent hashing ring representation.
 func NewConsistent(opts ...opt) *Consistent {
 	r := &Consistent{
-		members:   make(map[string]struct{}),
-		ring:      make(map[Hash]string),
-		hasher:    NewCRCHasher(), // default
-		nReplicas: defNReplicas,
+		members:           make(map[string]ringMember),
+		ring:              make(map[Hash]string),
+		remoteMembers:     make(map[string]remote.Member),
+		incarnations:      make(map[string]incarnation),
+		hasher:            NewCRCHasher(), // default
+		nReplicas:         defNReplicas,
+		reconcileInterval: defReconcileInterval,
+		identity:          IdentityName(),
+		logger:            slog.Default(),
 	}
 
 	for _, o := range opts {
 		o(r)
 	}
 
-	if r.isRemoteEnabled() {
-		r.handleRemote()
+	if r.remote != nil {
+		r.startRemote(context.Background())
 	}
 
 	return r
 }
 
-// Add adds a new server to the ring.
+// Add adds a new server to the ring. Servers added by hand are kept
+// when the ring is synced with its remote, until they are removed.
 // If the server is already present in the ring returns ErrSrvAlreadyExists.
 func (c *Consistent) Add(srv string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	return c.add(srv, ringMember{name: srv})
+}
+
+// AddWithAddr adds a new server to the ring along with
+// its network address, which can be retrieved by GetAddr.
+// If the server is already present in the ring returns ErrSrvAlreadyExists.
+func (c *Consistent) AddWithAddr(srv string, addr net.Addr) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.add(srv, ringMember{name: srv, addr: addr})
+}
+
+// add adds a new server to the ring.
+// Consistent lock must be held before calling this method.
+func (c *Consistent) add(srv string, m ringMember) error {
 	if _, ok := c.members[srv]; ok {
 		return ErrSrvAlreadyExists
 	}
 
-	c.members[srv] = struct{}{}
+	c.members[srv] = m
 
-	for i := 0; i < c.nReplicas; i++ {
+	for i := 0; i < c.vnodes(srv); i++ {
 		hash := c.hasher.Hash(c.srvKey(srv, i))
 		c.hashes = append(c.hashes, hash)
 		c.ring[hash] = srv
 	}
 
 	c.sortHashes()
+	c.epoch++
 
 	return nil
 }
@@ -94,17 +164,25 @@ func (c *Consistent) Remove(srv string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	return c.remove(srv)
+}
+
+// remove deletes the given server from the ring.
+// Consistent lock must be held before calling this method.
+func (c *Consistent) remove(srv string) error {
 	if _, ok := c.members[srv]; !ok {
 		return ErrSrvNotExists
 	}
 
+	n := c.vnodes(srv)
 	delete(c.members, srv)
 
-	for i := 0; i < c.nReplicas; i++ {
+	for i := 0; i < n; i++ {
 		delete(c.ring, c.hasher.Hash(c.srvKey(srv, i)))
 	}
 
 	c.updateHashes()
+	c.epoch++
 
 	return nil
 }
@@ -115,12 +193,189 @@ func (c *Consistent) Get(key string) (string, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.count() == 0 {
+	if len(c.hashes) == 0 {
 		return "", ErrNoSrvs
 	}
 
+	return c.lookup(key), nil
+}
+
+// GetN returns up to n distinct servers for the given key, in
+// preference order: the owner of the key first, followed by the
+// next servers clockwise in the ring. Fewer than n servers are
+// returned if the ring does not have enough of them.
+// If the ring has no servers returns ErrNoSrvs.
+func (c *Consistent) GetN(key string, n int) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.hashes) == 0 {
+		return nil, ErrNoSrvs
+	}
+
+	return c.getN(key, n), nil
+}
+
+// GetNearest returns the same servers as GetN, reordered by the
+// round trip time to them as measured by the ring remote, nearest
+// first. The local node is the nearest one, and servers with an
+// unknown round trip time are kept last in preference order. If
+// the remote does not measure round trip times, the order of GetN
+// is kept.
+// If the ring has no servers returns ErrNoSrvs.
+func (c *Consistent) GetNearest(key string, n int) ([]string, error) {
+	c.remoteMu.Lock()
+	reporter, _ := c.remote.(remote.RTTReporter)
+	c.remoteMu.Unlock()
+
+	c.mu.RLock()
+	if len(c.hashes) == 0 {
+		c.mu.RUnlock()
+		return nil, ErrNoSrvs
+	}
+	srvs := c.getN(key, n)
+	names := make([]string, len(srvs))
+	for i, srv := range srvs {
+		names[i] = c.members[srv].name
+	}
+	self := c.self
+	c.mu.RUnlock()
+
+	if reporter == nil {
+		return srvs, nil
+	}
+
+	type nearest struct {
+		srv   string
+		rtt   time.Duration
+		known bool
+	}
+	ranked := make([]nearest, len(srvs))
+	for i, srv := range srvs {
+		ranked[i].srv = srv
+		if srv == self {
+			ranked[i].known = true
+			continue
+		}
+		ranked[i].rtt, ranked[i].known = reporter.RTT(names[i])
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].known != ranked[j].known {
+			return ranked[i].known
+		}
+		return ranked[i].rtt < ranked[j].rtt
+	})
+	for i, r := range ranked {
+		srvs[i] = r.srv
+	}
+
+	return srvs, nil
+}
+
+// getN returns up to n distinct servers for the given key.
+// Consistent lock must be held and the ring must not be empty
+// before calling this method.
+func (c *Consistent) getN(key string, n int) []string {
+	if n <= 0 {
+		return nil
+	}
+
+	owner := c.lookup(key)
+	srvs := []string{owner}
+	seen := map[string]bool{owner: true}
+
+	idx := c.search(c.hasher.Hash(key))
+	for i := 0; i < len(c.hashes) && len(srvs) < n; i++ {
+		srv := c.ring[c.hashes[(idx+i)%len(c.hashes)]]
+		if seen[srv] {
+			continue
+		}
+		seen[srv] = true
+		srvs = append(srvs, srv)
+	}
+
+	return srvs
+}
+
+// GetWithEpoch returns the associated server in the ring for the given
+// key along with the ring epoch of the lookup, which can be attached to
+// requests so owners can reject the ones issued against an older ring.
+//
+// Epochs are comparable across the cluster when the ring remote is a
+// remote.EpochReplicator, such as the Gossiper: every ring advances its
+// epoch to the greatest one in the cluster before bumping it, so a ring
+// change seen by any node is ordered after every epoch it knew. Owners
+// should ObserveEpoch the epochs of the requests they accept, so their
+// later epochs are greater. Otherwise the epoch is local to the ring.
+// If the ring has no servers returns ErrNoSrvs.
+func (c *Consistent) GetWithEpoch(key string) (string, uint64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.hashes) == 0 {
+		return "", c.epoch, ErrNoSrvs
+	}
+
+	return c.lookup(key), c.epoch, nil
+}
+
+// Epoch returns the current ring epoch, which monotonically
+// increases on every addition or removal of a server. See
+// GetWithEpoch.
+func (c *Consistent) Epoch() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.epoch
+}
+
+// ObserveEpoch advances the ring epoch to the given one if it is
+// greater, e.g. the epoch attached to a request or published by
+// another node, without changing the ring.
+func (c *Consistent) ObserveEpoch(epoch uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.epoch = max(c.epoch, epoch)
+}
+
+// GetAddr returns the network address of the associated server
+// in the ring for the given key.
+// If the ring has no servers returns ErrNoSrvs, and if the server
+// address is not known returns ErrNoAddr.
+func (c *Consistent) GetAddr(key string) (net.Addr, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.hashes) == 0 {
+		return nil, ErrNoSrvs
+	}
+
+	srv := c.lookup(key)
+
+	addr := c.members[srv].addr
+	if addr == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoAddr, srv)
+	}
+
+	return addr, nil
+}
+
+// lookup returns the server which owns the given key, which is
+// the one the key is overridden to, if any, or otherwise the next
+// one clockwise in the ring.
+// Consistent lock must be held and the ring must not be empty
+// before calling this method.
+func (c *Consistent) lookup(key string) string {
+	if srv, ok := c.overrides[key]; ok && c.vnodes(srv) > 0 {
+		if _, ok := c.members[srv]; ok {
+			return srv
+		}
+	}
+
 	idx := c.search(c.hasher.Hash(key))
-	return c.ring[c.hashes[idx]], nil
+	return c.ring[c.hashes[idx]]
 }
 
 // search returns the position in the ring (hashes index)
@@ -169,33 +424,27 @@ func (c *Consistent) count() int {
 	return len(c.members)
 }
 
-func (c *Consistent) isRemoteEnabled() bool {
-	return c.remote != nil
-}
+// Members returns the servers which are part of the ring,
+// sorted by name.
+func (c *Consistent) Members() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-func (c *Consistent) handleRemote() {
-	eventsCh := c.remote.EventsCh()
+	members := make([]string, 0, len(c.members))
+	for m := range c.members {
+		members = append(members, m)
+	}
+	sort.Strings(members)
 
-	go func() {
-		for e := range eventsCh {
-			switch e.Typ {
-			case remote.EventJoin:
-				if err := c.Add(e.Name); err != nil {
-					c.handleRcvErr(e, err)
-				}
-			case remote.EventLeave:
-				if err := c.Remove(e.Name); err != nil {
-					c.handleRcvErr(e, err)
-				}
-			default:
-				log.Printf("warning: unknown event type: %v", e.Typ)
-			}
-		}
-	}()
+	return members
 }
 
+// Snapshot represents a point in time view of the ring.
 type Snapshot struct {
 	Members map[string][]Hash
+	// Epoch is the ring epoch at the time of the snapshot.
+	// See GetWithEpoch.
+	Epoch uint64
 }
 
 func (c *Consistent) Snapshot() Snapshot {
@@ -213,9 +462,36 @@ func (c *Consistent) Snapshot() Snapshot {
 
 	return Snapshot{
 		Members: members,
+		Epoch:   c.epoch,
 	}
 }
 
-func (c *Consistent) handleRcvErr(e remote.Event, err error) {
-	log.Printf("error processing remote event %v: %v", e, err)
+// Checksum returns a checksum of the ring members and their hashes,
+// which is equal for any two rings with the same members regardless
+// of the order in which they were added. The epoch is not part of it,
+// as it can differ between nodes which built the same ring.
+func (s Snapshot) Checksum() uint64 {
+	names := make([]string, 0, len(s.Members))
+	for m := range s.Members {
+		names = append(names, m)
+	}
+	sort.Strings(names)
+
+	h := fnv.New64a()
+	buf := make([]byte, 4)
+	for _, m := range names {
+		h.Write([]byte(m)) //nolint:errcheck
+		h.Write([]byte{0}) //nolint:errcheck
+
+		hashes := append([]Hash(nil), s.Members[m]...)
+		sort.Slice(hashes, func(i, j int) bool {
+			return hashes[i] < hashes[j]
+		})
+		for _, hash := range hashes {
+			binary.BigEndian.PutUint32(buf, uint32(hash))
+			h.Write(buf) //nolint:errcheck
+		}
+	}
+
+	return h.Sum64()
 }
@@ -3,6 +3,7 @@ package consistent
 import (
 	"errors"
 	"fmt"
+	"net"
 	"reflect"
 	"sort"
 	"testing"
@@ -329,6 +330,294 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestGetAddr(t *testing.T) {
+	t.Parallel()
+
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 7946}
+
+	testCases := []struct {
+		name     string
+		c        *Consistent
+		wantAddr net.Addr
+		wantErr  error
+	}{
+		{
+			name: "should get srv address",
+			c: func() *Consistent {
+				c := newTestC(t, 0)
+				if err := c.AddWithAddr("srv0", addr); err != nil {
+					t.Fatalf("error adding srv: %v", err)
+				}
+				return c
+			}(),
+			wantAddr: addr,
+		},
+		{
+			name:    "should return error srv has no address",
+			c:       newTestC(t, 1),
+			wantErr: ErrNoAddr,
+		},
+		{
+			name:    "should return error ring has no servers",
+			c:       newTestC(t, 0),
+			wantErr: ErrNoSrvs,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tc.c.GetAddr("any")
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("unexpected error. want: %v but got: %v", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tc.wantAddr) {
+				t.Fatalf("expected addr to be %v, but got %v", tc.wantAddr, got)
+			}
+		})
+	}
+}
+
+func TestGetN(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		nSrvs   int
+		n       int
+		wantLen int
+		wantErr error
+	}{
+		{
+			name:    "should return error ring has no servers",
+			n:       2,
+			wantErr: ErrNoSrvs,
+		},
+		{
+			name:    "should return no srvs for non positive n",
+			nSrvs:   3,
+			n:       0,
+			wantLen: 0,
+		},
+		{
+			name:    "should return n distinct srvs",
+			nSrvs:   5,
+			n:       3,
+			wantLen: 3,
+		},
+		{
+			name:    "should return all srvs if n exceeds them",
+			nSrvs:   2,
+			n:       5,
+			wantLen: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := newTestC(t, tc.nSrvs)
+
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("key%d", i)
+
+				srvs, err := c.GetN(key, tc.n)
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("unexpected error. want: %v but got: %v", tc.wantErr, err)
+				}
+				if err != nil {
+					return
+				}
+				if len(srvs) != tc.wantLen {
+					t.Fatalf("expected srvs len to be %d, but got %d", tc.wantLen, len(srvs))
+				}
+				if len(srvs) == 0 {
+					continue
+				}
+
+				if owner, _ := c.Get(key); srvs[0] != owner {
+					t.Fatalf("expected first srv to be owner %q, but got %q", owner, srvs[0])
+				}
+				seen := make(map[string]bool)
+				for _, srv := range srvs {
+					if seen[srv] {
+						t.Fatalf("expected distinct srvs, but got %v", srvs)
+					}
+					seen[srv] = true
+				}
+			}
+		})
+	}
+}
+
+func TestGetWithEpoch(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		c         *Consistent
+		wantEpoch uint64
+		wantErr   error
+	}{
+		{
+			name:      "should return zero epoch for empty ring",
+			c:         newTestC(t, 0),
+			wantEpoch: 0,
+			wantErr:   ErrNoSrvs,
+		},
+		{
+			name:      "should bump epoch on every add",
+			c:         newTestC(t, 3),
+			wantEpoch: 3,
+		},
+		{
+			name: "should bump epoch on every remove",
+			c: func() *Consistent {
+				c := newTestC(t, 3)
+				if err := c.Remove("srv1"); err != nil {
+					t.Fatalf("error removing srv: %v", err)
+				}
+				return c
+			}(),
+			wantEpoch: 4,
+		},
+		{
+			name: "should not bump epoch on failed changes",
+			c: func() *Consistent {
+				c := newTestC(t, 1)
+				c.Add("srv0")    //nolint:errcheck
+				c.Remove("srv9") //nolint:errcheck
+				return c
+			}(),
+			wantEpoch: 1,
+		},
+		{
+			name: "should keep epoch increasing after ring is empty",
+			c: func() *Consistent {
+				c := newTestC(t, 1)
+				if err := c.Remove("srv0"); err != nil {
+					t.Fatalf("error removing srv: %v", err)
+				}
+				return c
+			}(),
+			wantEpoch: 2,
+			wantErr:   ErrNoSrvs,
+		},
+		{
+			name: "should advance epoch to observed ones",
+			c: func() *Consistent {
+				c := newTestC(t, 1)
+				c.ObserveEpoch(10)
+				c.ObserveEpoch(5)
+				if err := c.Add("srv1"); err != nil {
+					t.Fatalf("error adding srv: %v", err)
+				}
+				return c
+			}(),
+			wantEpoch: 11,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, epoch, err := tc.c.GetWithEpoch("any")
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("unexpected error. want: %v but got: %v", tc.wantErr, err)
+			}
+			if epoch != tc.wantEpoch {
+				t.Fatalf("expected epoch to be %d, but got %d", tc.wantEpoch, epoch)
+			}
+			if err == nil {
+				if want, _ := tc.c.Get("any"); srv != want {
+					t.Fatalf("expected srv to be %q, but got %q", want, srv)
+				}
+			}
+			if snapEpoch := tc.c.Snapshot().Epoch; snapEpoch != tc.wantEpoch {
+				t.Fatalf("expected snapshot epoch to be %d, but got %d", tc.wantEpoch, snapEpoch)
+			}
+		})
+	}
+}
+
+func TestSnapshotChecksum(t *testing.T) {
+	t.Parallel()
+
+	newC := func(srvs ...string) *Consistent {
+		c := NewConsistent()
+		for _, srv := range srvs {
+			if err := c.Add(srv); err != nil {
+				t.Fatalf("error adding srv: %v", err)
+			}
+		}
+		return c
+	}
+
+	testCases := []struct {
+		name      string
+		c1        *Consistent
+		c2        *Consistent
+		wantEqual bool
+	}{
+		{
+			name:      "should be equal for empty rings",
+			c1:        newC(),
+			c2:        newC(),
+			wantEqual: true,
+		},
+		{
+			name:      "should be equal regardless of order",
+			c1:        newC("srv0", "srv1", "srv2"),
+			c2:        newC("srv2", "srv0", "srv1"),
+			wantEqual: true,
+		},
+		{
+			name: "should be equal regardless of epoch",
+			c1:   newC("srv0", "srv1"),
+			c2: func() *Consistent {
+				c := newC("srv0", "srv1", "srv2")
+				if err := c.Remove("srv2"); err != nil {
+					t.Fatalf("error removing srv: %v", err)
+				}
+				return c
+			}(),
+			wantEqual: true,
+		},
+		{
+			name: "should differ for different members",
+			c1:   newC("srv0", "srv1"),
+			c2:   newC("srv0", "srv2"),
+		},
+		{
+			name: "should differ for different replicas",
+			c1:   newC("srv0"),
+			c2: func() *Consistent {
+				c := NewConsistent(WithReplicas(10))
+				c.Add("srv0") //nolint:errcheck
+				return c
+			}(),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sum1, sum2 := tc.c1.Snapshot().Checksum(), tc.c2.Snapshot().Checksum()
+			if equal := sum1 == sum2; equal != tc.wantEqual {
+				t.Fatalf("expected checksums %d and %d equality to be %v", sum1, sum2, tc.wantEqual)
+			}
+		})
+	}
+}
+
 // newTestC creates a new Consistent for testing purposes.
 func newTestC(t *testing.T, nSrvs int, opts ...opt) *Consistent {
 	t.Helper()
@@ -0,0 +1,299 @@
+package consistenttest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ka3de/consistent"
+	"github.com/ka3de/consistent/pkg/remote"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// ClusterConfig defines the nodes of a Cluster.
+type ClusterConfig struct {
+	// Nodes is the number of nodes started with the cluster.
+	Nodes int
+	// Gossiper, if set, is invoked to modify the config of every
+	// Gossiper, which defaults to fast failure detection tuned for
+	// tests. The transport, name and logger are set by the cluster.
+	Gossiper func(config *remote.GossiperConfig)
+	// Ring, if set, creates the ring of every node from its remote.
+	// Defaults to a ring with the default options.
+	Ring func(r remote.Remoter) *consistent.Consistent
+}
+
+// Node is a node of a Cluster.
+type Node struct {
+	Name     string
+	Gossiper *remote.Gossiper
+	Ring     *consistent.Consistent
+}
+
+// Cluster is a cluster of nodes, each of them a Gossiper and the ring
+// tracking it, running in the same process over an in-memory network.
+// Nodes can be killed, restarted and partitioned in order to test
+// failure scenarios deterministically. Nodes are identified by their
+// index, in the order they are started.
+type Cluster struct {
+	t      testing.TB
+	config ClusterConfig
+	net    *network
+
+	mu    sync.Mutex
+	nodes []*Node // nil for the killed nodes
+}
+
+// NewCluster starts a new cluster with the configured number of nodes,
+// joining all of them to the first one, which is closed once the test
+// is done.
+func NewCluster(t testing.TB, config ClusterConfig) *Cluster {
+	t.Helper()
+
+	c := &Cluster{
+		t:      t,
+		config: config,
+		net:    newNetwork(),
+	}
+	t.Cleanup(c.Close)
+
+	for i := 0; i < config.Nodes; i++ {
+		c.Add()
+	}
+
+	return c
+}
+
+// Add starts a new node, joining it to the cluster,
+// and returns its index.
+func (c *Cluster) Add() int {
+	c.t.Helper()
+
+	c.mu.Lock()
+	i := len(c.nodes)
+	c.nodes = append(c.nodes, nil)
+	c.mu.Unlock()
+
+	c.start(i)
+
+	return i
+}
+
+// Node returns the node with the given index,
+// or nil if it has been killed.
+func (c *Cluster) Node(i int) *Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.nodes[i]
+}
+
+// Running returns the indexes of the running nodes.
+func (c *Cluster) Running() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var running []int
+	for i, n := range c.nodes {
+		if n != nil {
+			running = append(running, i)
+		}
+	}
+
+	return running
+}
+
+// Kill stops the node with the given index without leaving the
+// cluster, so the rest of the nodes have to detect it as failed.
+func (c *Cluster) Kill(i int) {
+	c.t.Helper()
+
+	c.stop(i, false)
+}
+
+// Leave gracefully leaves the cluster and stops
+// the node with the given index.
+func (c *Cluster) Leave(i int) {
+	c.t.Helper()
+
+	c.stop(i, true)
+}
+
+// Restart starts again the stopped node with the given index,
+// with the same name and address, joining it to the cluster.
+func (c *Cluster) Restart(i int) {
+	c.t.Helper()
+
+	if c.Node(i) != nil {
+		c.t.Fatalf("node %d is running", i)
+	}
+	c.start(i)
+}
+
+// Partition splits the cluster in the given groups of node indexes,
+// which cannot reach the nodes in other groups. The nodes which are
+// not in any group form an additional group.
+func (c *Cluster) Partition(groups ...[]int) {
+	c.net.heal()
+	for g, nodes := range groups {
+		addrs := make([]string, len(nodes))
+		for j, i := range nodes {
+			addrs[j] = nodeAddr(i).String()
+		}
+		c.net.partition(g+1, addrs...)
+	}
+}
+
+// Heal removes any partition and joins every running node to the
+// rest of them, as gossip never contacts again the nodes detected as
+// failed.
+func (c *Cluster) Heal() {
+	c.t.Helper()
+
+	c.net.heal()
+
+	running := c.Running()
+	seeds := make([]string, len(running))
+	for j, i := range running {
+		seeds[j] = nodeAddr(i).String()
+	}
+	for _, i := range running {
+		if _, err := c.Node(i).Gossiper.Join(context.Background(), seeds); err != nil {
+			c.t.Fatalf("error joining gossiper %d: %v", i, err)
+		}
+	}
+}
+
+// WaitConverged waits until the ring of every running node has all
+// the running nodes as members, failing the test after the timeout.
+func (c *Cluster) WaitConverged(timeout time.Duration) {
+	c.t.Helper()
+
+	running := c.Running()
+	c.WaitMembers(timeout, running, running...)
+}
+
+// WaitMembers waits until the rings of the given nodes have exactly
+// the given members, e.g. the nodes on their side of a partition,
+// and are equal, failing the test after the timeout.
+func (c *Cluster) WaitMembers(timeout time.Duration, nodes []int, members ...int) {
+	c.t.Helper()
+
+	names := make([]string, len(members))
+	for j, i := range members {
+		names[j] = nodeName(i)
+	}
+	sort.Strings(names)
+
+	waitUntil(c.t, timeout, func() error {
+		var first *consistent.Consistent
+		for _, i := range nodes {
+			n := c.Node(i)
+			if n == nil {
+				return fmt.Errorf("node %d is not running", i)
+			}
+			if err := MembersEqualTo(names...).Check(n.Ring); err != nil {
+				return fmt.Errorf("node %s: %w", n.Name, err)
+			}
+			if first == nil {
+				first = n.Ring
+				continue
+			}
+			if err := SameRingAs(first).Check(n.Ring); err != nil {
+				return fmt.Errorf("node %s: %w", n.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Close stops all the running nodes.
+func (c *Cluster) Close() {
+	for _, i := range c.Running() {
+		c.stop(i, false)
+	}
+}
+
+// start starts the node with the given index, joining
+// it to the rest of the running nodes, if any.
+func (c *Cluster) start(i int) {
+	c.t.Helper()
+
+	config := remote.GossiperConfig{
+		Network:        remote.GossiperNetworkLocal,
+		ProbeInterval:  100 * time.Millisecond,
+		ProbeTimeout:   50 * time.Millisecond,
+		GossipInterval: 20 * time.Millisecond,
+		// Push/pull syncs the members lost during partitions
+		PushPullInterval: 500 * time.Millisecond,
+		SuspicionMult:    2,
+		Join: remote.JoinConfig{
+			RejoinInterval: 500 * time.Millisecond,
+		},
+	}
+	if c.config.Gossiper != nil {
+		c.config.Gossiper(&config)
+	}
+	config.NodeName = nodeName(i)
+	config.Transport = c.net.newTransport(nodeAddr(i))
+	config.Logger = discardLogger
+
+	g, err := remote.NewGossiper(config)
+	if err != nil {
+		c.t.Fatalf("error creating gossiper %d: %v", i, err)
+	}
+	if err := g.Start(); err != nil {
+		c.t.Fatalf("error starting gossiper %d: %v", i, err)
+	}
+
+	var seeds []string
+	for _, j := range c.Running() {
+		seeds = append(seeds, nodeAddr(j).String())
+	}
+	if _, err := g.Join(context.Background(), seeds); err != nil {
+		g.Shutdown() //nolint:errcheck
+		c.t.Fatalf("error joining gossiper %d: %v", i, err)
+	}
+
+	var ring *consistent.Consistent
+	if c.config.Ring != nil {
+		ring = c.config.Ring(g)
+	} else {
+		ring = consistent.NewConsistent(consistent.WithRemote(g), consistent.WithLogger(discardLogger))
+	}
+
+	c.mu.Lock()
+	c.nodes[i] = &Node{Name: config.NodeName, Gossiper: g, Ring: ring}
+	c.mu.Unlock()
+}
+
+// stop stops the node with the given index,
+// leaving the cluster first if graceful.
+func (c *Cluster) stop(i int, graceful bool) {
+	c.t.Helper()
+
+	c.mu.Lock()
+	n := c.nodes[i]
+	c.nodes[i] = nil
+	c.mu.Unlock()
+	if n == nil {
+		return
+	}
+
+	n.Ring.Close() //nolint:errcheck
+	if graceful {
+		if err := n.Gossiper.Leave(time.Second); err != nil {
+			c.t.Errorf("error leaving gossiper %d: %v", i, err)
+		}
+	}
+	if err := n.Gossiper.Shutdown(); err != nil {
+		c.t.Errorf("error shutting down gossiper %d: %v", i, err)
+	}
+}
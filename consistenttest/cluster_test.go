@@ -0,0 +1,39 @@
+package consistenttest
+
+import (
+	"testing"
+	"time"
+)
+
+const convergeTimeout = 10 * time.Second
+
+func TestCluster(t *testing.T) {
+	t.Parallel()
+
+	c := NewCluster(t, ClusterConfig{Nodes: 3})
+	c.WaitConverged(convergeTimeout)
+	Check(t, c.Node(0).Ring, OwnersAreMembers(Keys(100)), DistinctReplicas(Keys(100), 2))
+
+	c.Kill(1)
+	c.WaitConverged(convergeTimeout)
+
+	c.Restart(1)
+	c.WaitConverged(convergeTimeout)
+
+	c.Leave(2)
+	c.WaitMembers(convergeTimeout, []int{0, 1}, 0, 1)
+}
+
+func TestClusterPartition(t *testing.T) {
+	t.Parallel()
+
+	c := NewCluster(t, ClusterConfig{Nodes: 4})
+	c.WaitConverged(convergeTimeout)
+
+	c.Partition([]int{0, 1}, []int{2, 3})
+	c.WaitMembers(convergeTimeout, []int{0, 1}, 0, 1)
+	c.WaitMembers(convergeTimeout, []int{2, 3}, 2, 3)
+
+	c.Heal()
+	c.WaitConverged(convergeTimeout)
+}
@@ -0,0 +1,260 @@
+// Package consistenttest provides checkers of the invariants of a
+// consistent hashing ring, and helpers to verify them in tests.
+package consistenttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ka3de/consistent"
+	"github.com/ka3de/consistent/pkg/remote"
+	"github.com/ka3de/consistent/pkg/remote/remotetest"
+)
+
+// DefaultPollInterval is the interval on which
+// WaitFor verifies the checkers again.
+const DefaultPollInterval = 10 * time.Millisecond
+
+// Checker verifies an invariant of a ring.
+type Checker interface {
+	// Name identifies the checker in failure messages.
+	Name() string
+	// Check returns an error describing why the
+	// ring does not satisfy the invariant, if so.
+	Check(c *consistent.Consistent) error
+}
+
+type checker struct {
+	name  string
+	check func(c *consistent.Consistent) error
+}
+
+// NewChecker returns a Checker with the given name
+// which verifies the ring with the given function.
+func NewChecker(name string, check func(c *consistent.Consistent) error) Checker {
+	return &checker{name: name, check: check}
+}
+
+func (ch *checker) Name() string {
+	return ch.name
+}
+
+func (ch *checker) Check(c *consistent.Consistent) error {
+	return ch.check(c)
+}
+
+// Check verifies the ring with the given checkers,
+// failing the test on the first one which fails.
+func Check(t testing.TB, c *consistent.Consistent, checkers ...Checker) {
+	t.Helper()
+
+	if err := check(c, checkers); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// WaitFor verifies the ring with the given checkers until all of them
+// pass, which is useful when the ring is updated in background by a
+// remote. Fails the test if they do not pass before the timeout.
+func WaitFor(t testing.TB, c *consistent.Consistent, timeout time.Duration, checkers ...Checker) {
+	t.Helper()
+
+	waitUntil(t, timeout, func() error {
+		return check(c, checkers)
+	})
+}
+
+// Sync blocks until the rings following the given fake remote have
+// applied every event pushed to it, so the ring can be verified with
+// Check right away. Fails the test if they are not applied before the
+// timeout.
+func Sync(t testing.TB, r *remotetest.Remoter, timeout time.Duration) {
+	t.Helper()
+
+	// Rings apply the remote events one at a time, so once they
+	// receive a no-op event pushed after the rest, every previous
+	// one has been applied
+	r.Push(remote.Event{Typ: remote.EventRingConfig})
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := r.Flush(ctx); err != nil {
+		t.Fatalf("error waiting for events to be applied: %v", err)
+	}
+}
+
+// waitUntil polls cond until it returns no error, failing
+// the test with the last error after the timeout.
+func waitUntil(t testing.TB, timeout time.Duration, cond func() error) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := cond()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for checks: %v", err)
+		}
+		time.Sleep(DefaultPollInterval)
+	}
+}
+
+func check(c *consistent.Consistent, checkers []Checker) error {
+	for _, ch := range checkers {
+		if err := ch.Check(c); err != nil {
+			return fmt.Errorf("error verifying check %s: %w", ch.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// Keys returns n distinct keys to look up in checkers.
+func Keys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+
+	return keys
+}
+
+// MembersEqualTo verifies that the ring members
+// are exactly the given ones, in any order.
+func MembersEqualTo(members ...string) Checker {
+	want := make(map[string]bool, len(members))
+	for _, m := range members {
+		want[m] = true
+	}
+
+	return NewChecker("MembersEqualTo", func(c *consistent.Consistent) error {
+		got := make(map[string]bool)
+		for _, m := range c.Members() {
+			got[m] = true
+		}
+		if !reflect.DeepEqual(got, want) {
+			return fmt.Errorf("expected members to be %v, but got %v", members, c.Members())
+		}
+
+		return nil
+	})
+}
+
+// OwnersAreMembers verifies that every key is
+// owned by a member of the ring.
+func OwnersAreMembers(keys []string) Checker {
+	return NewChecker("OwnersAreMembers", func(c *consistent.Consistent) error {
+		members := make(map[string]bool)
+		for _, m := range c.Members() {
+			members[m] = true
+		}
+
+		for _, k := range keys {
+			srv, err := c.Get(k)
+			if err != nil {
+				return fmt.Errorf("error getting owner of %q: %w", k, err)
+			}
+			if !members[srv] {
+				return fmt.Errorf("owner %q of %q is not a member", srv, k)
+			}
+		}
+
+		return nil
+	})
+}
+
+// DistinctReplicas verifies that GetN returns, for every key, its
+// owner first followed by distinct servers, up to n or the number of
+// servers owning keys in the ring.
+func DistinctReplicas(keys []string, n int) Checker {
+	return NewChecker("DistinctReplicas", func(c *consistent.Consistent) error {
+		want := min(n, len(c.Snapshot().Members))
+
+		for _, k := range keys {
+			srvs, err := c.GetN(k, n)
+			if err != nil {
+				return fmt.Errorf("error getting replicas of %q: %w", k, err)
+			}
+			if len(srvs) != want {
+				return fmt.Errorf("expected %d replicas of %q, but got %v", want, k, srvs)
+			}
+
+			owner, err := c.Get(k)
+			if err != nil {
+				return fmt.Errorf("error getting owner of %q: %w", k, err)
+			}
+			if srvs[0] != owner {
+				return fmt.Errorf("expected first replica of %q to be its owner %q, but got %v", k, owner, srvs)
+			}
+
+			seen := make(map[string]bool, len(srvs))
+			for _, srv := range srvs {
+				if seen[srv] {
+					return fmt.Errorf("duplicated replica %q of %q: %v", srv, k, srvs)
+				}
+				seen[srv] = true
+			}
+		}
+
+		return nil
+	})
+}
+
+// Balanced verifies that no server owns more than maxRatio
+// times its even share of the given keys.
+func Balanced(keys []string, maxRatio float64) Checker {
+	return NewChecker("Balanced", func(c *consistent.Consistent) error {
+		n := len(c.Snapshot().Members)
+		if n == 0 {
+			return consistent.ErrNoSrvs
+		}
+
+		owned := make(map[string]int)
+		for _, k := range keys {
+			srv, err := c.Get(k)
+			if err != nil {
+				return fmt.Errorf("error getting owner of %q: %w", k, err)
+			}
+			owned[srv]++
+		}
+
+		share := float64(len(keys)) / float64(n)
+		for srv, count := range owned {
+			if ratio := float64(count) / share; ratio > maxRatio {
+				return fmt.Errorf("server %q owns %d keys, %.2f times its share", srv, count, ratio)
+			}
+		}
+
+		return nil
+	})
+}
+
+// SameRingAs verifies that the ring has the same members and
+// hashes as the given one, regardless of their epochs.
+func SameRingAs(other *consistent.Consistent) Checker {
+	return NewChecker("SameRingAs", func(c *consistent.Consistent) error {
+		if c.Snapshot().Checksum() != other.Snapshot().Checksum() {
+			return fmt.Errorf("expected ring members %v, but got %v", other.Members(), c.Members())
+		}
+
+		return nil
+	})
+}
+
+// NotStale verifies that the ring keeps tracking its remote.
+func NotStale() Checker {
+	return NewChecker("NotStale", func(c *consistent.Consistent) error {
+		if c.Stale() {
+			return errors.New("ring is stale")
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,144 @@
+package consistenttest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ka3de/consistent"
+	"github.com/ka3de/consistent/pkg/remote"
+	"github.com/ka3de/consistent/pkg/remote/remotetest"
+)
+
+func TestCheckers(t *testing.T) {
+	t.Parallel()
+
+	keys := Keys(1000)
+
+	testCases := []struct {
+		name    string
+		members []string
+		checker Checker
+		wantErr bool
+	}{
+		{
+			name:    "members equal",
+			members: []string{"srv0", "srv1"},
+			checker: MembersEqualTo("srv1", "srv0"),
+		},
+		{
+			name:    "members differ",
+			members: []string{"srv0", "srv1"},
+			checker: MembersEqualTo("srv0"),
+			wantErr: true,
+		},
+		{
+			name:    "owners are members",
+			members: []string{"srv0", "srv1", "srv2"},
+			checker: OwnersAreMembers(keys),
+		},
+		{
+			name:    "owners of empty ring",
+			checker: OwnersAreMembers(keys),
+			wantErr: true,
+		},
+		{
+			name:    "distinct replicas",
+			members: []string{"srv0", "srv1", "srv2"},
+			checker: DistinctReplicas(keys, 2),
+		},
+		{
+			name:    "fewer servers than replicas",
+			members: []string{"srv0", "srv1"},
+			checker: DistinctReplicas(keys, 3),
+		},
+		{
+			name:    "balanced",
+			members: []string{"srv0", "srv1", "srv2"},
+			checker: Balanced(keys, 2),
+		},
+		{
+			name:    "unbalanced",
+			members: []string{"srv0", "srv1", "srv2"},
+			checker: Balanced(keys, 1),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := consistent.NewConsistent()
+			for _, m := range tc.members {
+				if err := c.Add(m); err != nil {
+					t.Fatalf("error adding member: %v", err)
+				}
+			}
+
+			err := tc.checker.Check(c)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error. want error: %t but got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	t.Parallel()
+
+	r := remotetest.NewRemoter()
+	t.Cleanup(func() { r.Close() }) //nolint:errcheck
+
+	c := consistent.NewConsistent(consistent.WithRemote(r))
+	t.Cleanup(func() { c.Close() }) //nolint:errcheck
+
+	var want []string
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("srv%02d", i)
+		r.Join(remote.Member{Name: name})
+		want = append(want, name)
+	}
+	r.Leave("srv00")
+
+	// Events are applied once synced, without polling
+	Sync(t, r, 5*time.Second)
+	Check(t, c, MembersEqualTo(want[1:]...))
+}
+
+func TestWaitFor(t *testing.T) {
+	t.Parallel()
+
+	r := remotetest.NewRemoter(remote.Member{Name: "srv0"})
+	t.Cleanup(func() { r.Close() }) //nolint:errcheck
+
+	c := consistent.NewConsistent(consistent.WithRemote(r))
+	t.Cleanup(func() { c.Close() }) //nolint:errcheck
+
+	Check(t, c, MembersEqualTo("srv0"), NotStale())
+
+	r.Join(remote.Member{Name: "srv1"})
+	r.Join(remote.Member{Name: "srv2"})
+	r.Leave("srv0")
+	WaitFor(t, c, 5*time.Second, MembersEqualTo("srv1", "srv2"), OwnersAreMembers(Keys(100)))
+
+	other := consistent.NewConsistent()
+	for _, m := range []string{"srv2", "srv1"} {
+		if err := other.Add(m); err != nil {
+			t.Fatalf("error adding member: %v", err)
+		}
+	}
+	Check(t, c, SameRingAs(other))
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("error closing remoter: %v", err)
+	}
+	WaitFor(t, c, 5*time.Second, NewChecker("Stale", func(c *consistent.Consistent) error {
+		if !c.Stale() {
+			return errors.New("ring is not stale")
+		}
+		return nil
+	}))
+}
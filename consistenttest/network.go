@@ -0,0 +1,193 @@
+package consistenttest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/memberlist"
+)
+
+// packetQueueSize is the number of packets a transport
+// buffers before dropping them, as an UDP socket would.
+const packetQueueSize = 1024
+
+// errUnreachable indicates that a node cannot be reached,
+// because it is down or in a different partition.
+var errUnreachable = errors.New("node is unreachable")
+
+// network is an in-memory network connecting memberlist transports,
+// which can be partitioned in groups of nodes unable to reach each
+// other.
+type network struct {
+	mu         sync.Mutex
+	transports map[string]*transport
+	groups     map[string]int
+}
+
+func newNetwork() *network {
+	return &network{
+		transports: make(map[string]*transport),
+		groups:     make(map[string]int),
+	}
+}
+
+// newTransport creates a new transport bound to the given
+// address, replacing any previous one bound to it.
+func (n *network) newTransport(addr *net.TCPAddr) *transport {
+	t := &transport{
+		net:      n,
+		addr:     addr,
+		packetCh: make(chan *memberlist.Packet, packetQueueSize),
+		streamCh: make(chan net.Conn),
+		done:     make(chan struct{}),
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.transports[addr.String()] = t
+
+	return t
+}
+
+// partition assigns the given addresses to a group, isolating them
+// from the nodes in other groups. Nodes are in group zero by default.
+func (n *network) partition(group int, addrs ...string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, a := range addrs {
+		n.groups[a] = group
+	}
+}
+
+// heal moves every node back to the default group.
+func (n *network) heal() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.groups = make(map[string]int)
+}
+
+// route returns the running transport bound to the given
+// address, if it is reachable from the given source.
+func (n *network) route(from *net.TCPAddr, to string) (*transport, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	t, ok := n.transports[to]
+	if !ok || t.isShutdown() || n.groups[from.String()] != n.groups[to] {
+		return nil, fmt.Errorf("%w: %s", errUnreachable, to)
+	}
+
+	return t, nil
+}
+
+// transport is a memberlist.NodeAwareTransport
+// which communicates through a network.
+type transport struct {
+	net      *network
+	addr     *net.TCPAddr
+	packetCh chan *memberlist.Packet
+	streamCh chan net.Conn
+
+	shutdownOnce sync.Once
+	done         chan struct{}
+}
+
+var _ memberlist.NodeAwareTransport = (*transport)(nil)
+
+func (t *transport) FinalAdvertiseAddr(string, int) (net.IP, int, error) {
+	return t.addr.IP, t.addr.Port, nil
+}
+
+func (t *transport) WriteTo(b []byte, addr string) (time.Time, error) {
+	return t.WriteToAddress(b, memberlist.Address{Addr: addr})
+}
+
+// WriteToAddress delivers the packet to the destination, or drops it
+// if the destination is unreachable or its queue is full.
+func (t *transport) WriteToAddress(b []byte, a memberlist.Address) (time.Time, error) {
+	now := time.Now()
+
+	dest, err := t.net.route(t.addr, a.Addr)
+	if err != nil {
+		return now, nil
+	}
+
+	p := &memberlist.Packet{
+		Buf:       append([]byte(nil), b...),
+		From:      &net.UDPAddr{IP: t.addr.IP, Port: t.addr.Port},
+		Timestamp: now,
+	}
+	select {
+	case dest.packetCh <- p:
+	default:
+	}
+
+	return now, nil
+}
+
+func (t *transport) PacketCh() <-chan *memberlist.Packet {
+	return t.packetCh
+}
+
+func (t *transport) DialTimeout(addr string, timeout time.Duration) (net.Conn, error) {
+	return t.DialAddressTimeout(memberlist.Address{Addr: addr}, timeout)
+}
+
+// DialAddressTimeout opens a stream to the destination,
+// failing if it is unreachable.
+func (t *transport) DialAddressTimeout(a memberlist.Address, timeout time.Duration) (net.Conn, error) {
+	dest, err := t.net.route(t.addr, a.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	local, remote := net.Pipe()
+	select {
+	case dest.streamCh <- remote:
+		return local, nil
+	case <-dest.done:
+	case <-time.After(timeout):
+	}
+	local.Close()
+	remote.Close()
+
+	return nil, fmt.Errorf("%w: %s", errUnreachable, a.Addr)
+}
+
+func (t *transport) StreamCh() <-chan net.Conn {
+	return t.streamCh
+}
+
+func (t *transport) Shutdown() error {
+	t.shutdownOnce.Do(func() {
+		close(t.done)
+	})
+
+	return nil
+}
+
+func (t *transport) isShutdown() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// nodeAddr returns the address of the node with the given index.
+func nodeAddr(i int) *net.TCPAddr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 7946 + i}
+}
+
+// nodeName returns the name of the node with the given index.
+func nodeName(i int) string {
+	return "node-" + strconv.Itoa(i)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -11,9 +12,10 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
-	"sync"
 	"syscall"
+	"time"
 
 	"github.com/ka3de/consistent"
 	"github.com/ka3de/consistent/pkg/remote"
@@ -21,34 +23,46 @@ import (
 
 func main() {
 	var (
-		nodeName    string
-		apiPort     int
-		nodePort    int
-		nodeList    string
-		nodeListDNS string
-		network     string
+		nodeName     string
+		apiPort      int
+		nodePort     int
+		nodeList     string
+		nodeListDNS  string
+		network      string
+		dataDir      string
+		cluster      string
+		role         string
+		ringRole     string
+		joinDeadline time.Duration
 	)
 
-	flag.StringVar(&nodeName, "name", rndNodeName(), "node name")
+	flag.StringVar(&nodeName, "name", "", "node name. defaults to the node id persisted in the data dir, or to a random name")
+	flag.StringVar(&dataDir, "data-dir", "", "directory on which the node id is persisted")
 	flag.IntVar(&apiPort, "api-port", rndPort(), "http API port to bind to")
 	flag.IntVar(&nodePort, "port", rndPort(), "gossip node port to bind to")
 	flag.StringVar(&nodeList, "nodelist", "", "gossip node list to join to")
 	flag.StringVar(&nodeListDNS, "nodelist-dns", "", "DNS name to resolve gossip node list from")
 	flag.StringVar(&network, "network", "LOCAL", "network type on which cluster operates in. possible values are: LOCAL, LAN, WAN")
 
+	flag.StringVar(&cluster, "cluster", "", "cluster name, nodes of a different cluster are rejected")
+	flag.StringVar(&role, "role", "", "role announced by the node")
+	flag.StringVar(&ringRole, "ring-role", "", "if set, only nodes with this role are part of the ring")
+	flag.DurationVar(&joinDeadline, "join-deadline", 30*time.Second, "max time to keep retrying to join the node list")
+
 	flag.Parse()
 
-	var wg sync.WaitGroup
-	ctx, cancel := context.WithCancel(context.Background())
+	if nodeName == "" {
+		var err error
+		if nodeName, err = defaultNodeName(dataDir); err != nil {
+			log.Fatalf("error building node name: %v", err)
+		}
+	}
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	// Build Consistent
-	nodes, err := parseNodeList(nodeList, nodeListDNS, nodePort)
-	if err != nil {
-		log.Fatalf("error parsing node list: %v", err)
-	}
+	nodes := parseNodeList(nodeList)
 
 	log.Printf("starting node with name: %s port: %d", nodeName, nodePort)
 	gNetwork, err := parseNetwork(network)
@@ -56,22 +70,46 @@ func main() {
 		log.Fatalf("error parsing network: %v", err)
 	}
 	gConfig := remote.GossiperConfig{
-		NodeName: nodeName,
-		NodeList: nodes,
-		Network:  gNetwork,
-		Port:     nodePort,
+		NodeName:    nodeName,
+		Network:     gNetwork,
+		Port:        nodePort,
+		ClusterName: cluster,
+		Join: remote.JoinConfig{
+			Deadline:       joinDeadline,
+			AllowDegraded:  true,
+			RejoinInterval: 10 * time.Second,
+		},
+	}
+	if role != "" {
+		gConfig.Tags = map[string]string{"role": role}
 	}
-	g, err := remote.NewGossiper(ctx, &wg, gConfig)
+	if nodeListDNS != "" {
+		gConfig.Join.Resolver = func(context.Context) ([]string, error) {
+			return resolveNodeList(nodeListDNS, nodePort)
+		}
+	}
+	g, err := remote.NewGossiper(gConfig)
 	if err != nil {
 		log.Fatalf("error building remote gossiper: %v", err)
 	}
+	if err := g.Start(); err != nil {
+		log.Fatalf("error starting remote gossiper: %v", err)
+	}
+	if _, err := g.Join(context.Background(), nodes); err != nil {
+		log.Fatalf("error joining the cluster: %v", err)
+	}
 
-	c := consistent.NewConsistent(consistent.WithRemote(g))
+	var filter consistent.MemberFilter
+	if ringRole != "" {
+		filter = consistent.MatchTags(map[string]string{"role": ringRole})
+	}
+	c := consistent.NewConsistent(consistent.WithRemote(g), consistent.WithMemberFilter(filter))
 
 	// Build HTTP API
 	log.Printf("starting node HTTP API with port: %d", apiPort)
 	http.HandleFunc("/consistent/snapshot", ringHandler(nodeName, c))
 	http.HandleFunc("/consistent/srv", srvHandler(nodeName, c))
+	http.HandleFunc("/consistent/config", configHandler(c))
 
 	go func() {
 		log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", apiPort), nil))
@@ -79,20 +117,27 @@ func main() {
 	}()
 
 	<-stop
-	cancel()
-	wg.Wait()
+	c.Close() //nolint:errcheck
+	if err := g.Close(); err != nil {
+		log.Printf("error closing remote gossiper: %v", err)
+	}
 }
 
 type ringResponse struct {
-	From string                       `json:"from"`
-	Srvs map[string][]consistent.Hash `json:"srvs"`
+	From     string                       `json:"from"`
+	Srvs     map[string][]consistent.Hash `json:"srvs"`
+	Epoch    uint64                       `json:"epoch"`
+	Checksum uint64                       `json:"checksum"`
 }
 
 func ringHandler(srv string, c *consistent.Consistent) http.HandlerFunc {
 	return func(w http.ResponseWriter, _ *http.Request) {
+		snap := c.Snapshot()
 		body, err := json.Marshal(ringResponse{
-			From: srv,
-			Srvs: c.Snapshot().Members,
+			From:     srv,
+			Srvs:     snap.Members,
+			Epoch:    snap.Epoch,
+			Checksum: snap.Checksum(),
 		})
 		if err != nil {
 			w.Write([]byte(fmt.Sprintf("error marshaling response: %v", err))) //nolint:errcheck
@@ -108,8 +153,10 @@ func ringHandler(srv string, c *consistent.Consistent) http.HandlerFunc {
 }
 
 type srvResponse struct {
-	From string `json:"from"`
-	Srv  string `json:"srv"`
+	From  string `json:"from"`
+	Srv   string `json:"srv"`
+	Addr  string `json:"addr,omitempty"`
+	Epoch uint64 `json:"epoch"`
 }
 
 func srvHandler(srv string, c *consistent.Consistent) http.HandlerFunc {
@@ -119,15 +166,22 @@ func srvHandler(srv string, c *consistent.Consistent) http.HandlerFunc {
 			w.WriteHeader(http.StatusBadRequest)
 		}
 
-		srv, err := c.Get(key)
+		srv, epoch, err := c.GetWithEpoch(key)
 		if err != nil {
 			w.Write([]byte(fmt.Sprintf("error retrieving server: %v", err))) //nolint:errcheck
 			w.WriteHeader(http.StatusInternalServerError)
 		}
 
+		var addr string
+		if a, err := c.GetAddr(key); err == nil {
+			addr = a.String()
+		}
+
 		body, err := json.Marshal(srvResponse{
-			From: srv,
-			Srv:  srv,
+			From:  srv,
+			Srv:   srv,
+			Addr:  addr,
+			Epoch: epoch,
 		})
 		if err != nil {
 			w.Write([]byte(fmt.Sprintf("error marshaling response: %v", err))) //nolint:errcheck
@@ -142,17 +196,51 @@ func srvHandler(srv string, c *consistent.Consistent) http.HandlerFunc {
 	}
 }
 
-func parseNodeList(nodeList, nodeListDNS string, nodePort int) ([]string, error) {
-	if nodeListDNS != "" {
-		return resolveNodeList(nodeListDNS, nodePort)
+// configHandler changes the ring config, which is replicated to the
+// whole cluster, e.g. /consistent/config?srv=node-a&weight=2,
+// /consistent/config?srv=node-a&draining=true or
+// /consistent/config?key=test&override=node-a.
+func configHandler(c *consistent.Consistent) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		var err error
+		switch {
+		case q.Has("weight"):
+			var weight float64
+			weight, err = strconv.ParseFloat(q.Get("weight"), 64)
+			if err == nil {
+				err = c.SetWeight(q.Get("srv"), weight)
+			}
+		case q.Has("draining"):
+			var draining bool
+			draining, err = strconv.ParseBool(q.Get("draining"))
+			if err == nil {
+				err = c.SetDraining(q.Get("srv"), draining)
+			}
+		case q.Has("override"):
+			err = c.SetOverride(q.Get("key"), q.Get("override"))
+		default:
+			err = errors.New("no config change requested")
+		}
+
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("error changing ring config: %v", err))) //nolint:errcheck
+		}
 	}
+}
 
+// parseNodeList parses the static node list. The node list DNS
+// name, if any, is resolved by the join resolver on every join
+// attempt instead, so it can be unresolvable at startup.
+func parseNodeList(nodeList string) []string {
 	nodes := strings.Split(nodeList, ",")
 	if len(nodes) > 0 && nodes[0] == "" {
-		return nil, nil
+		return nil
 	}
 
-	return nodes, nil
+	return nodes
 }
 
 func resolveNodeList(nodeListDNS string, nodePort int) ([]string, error) {
@@ -167,7 +255,7 @@ func resolveNodeList(nodeListDNS string, nodePort int) ([]string, error) {
 func ipsToStr(ips []net.IP, port int) []string {
 	ss := make([]string, len(ips))
 	for i, ip := range ips {
-		ss[i] = fmt.Sprintf("%s:%d", ip.To4().String(), port)
+		ss[i] = net.JoinHostPort(ip.String(), strconv.Itoa(port))
 	}
 
 	return ss
@@ -188,8 +276,21 @@ func parseNetwork(network string) (gNetwork remote.GossiperNetwork, err error) {
 	return
 }
 
-func rndNodeName() string {
-	return fmt.Sprintf("node-%d", rndBound(0, 100))
+func defaultNodeName(dataDir string) (string, error) {
+	var (
+		id  string
+		err error
+	)
+	if dataDir != "" {
+		id, err = remote.NodeID(dataDir)
+	} else {
+		id, err = remote.NewNodeID()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return "node-" + id, nil
 }
 
 func rndPort() int {
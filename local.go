@@ -0,0 +1,127 @@
+package consistent
+
+import (
+	"errors"
+
+	"github.com/ka3de/consistent/pkg/remote"
+)
+
+// ErrNoSelf indicates that the ring does not know which member is the local node.
+var ErrNoSelf = errors.New("ring has no local member")
+
+// Range represents an interval of hashes [Start, End) of the ring.
+// If Start is not lower than End the interval wraps around the end
+// of the ring, so a range where both are equal covers the whole ring.
+type Range struct {
+	Start Hash
+	End   Hash
+}
+
+// Contains reports whether the given hash is within the range.
+func (r Range) Contains(h Hash) bool {
+	if r.Start < r.End {
+		return h >= r.Start && h < r.End
+	}
+	return h >= r.Start || h < r.End
+}
+
+// Self returns the ring identity of the local node, or an
+// empty string if it is unknown.
+func (c *Consistent) Self() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.self
+}
+
+// IsLocal reports whether the given key is owned by the local node.
+// Returns false if the local node is unknown or the ring has no servers.
+func (c *Consistent) IsLocal(key string) bool {
+	owns, _ := c.Owns(key)
+	return owns
+}
+
+// Owns reports whether the given key is owned by the local node.
+// If the local node is unknown returns ErrNoSelf, and if the ring
+// has no servers returns ErrNoSrvs.
+func (c *Consistent) Owns(key string) (bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.self == "" {
+		return false, ErrNoSelf
+	}
+	if len(c.hashes) == 0 {
+		return false, ErrNoSrvs
+	}
+
+	// Short-circuit the lookup when the local node is
+	// either not a member, drained or the only one
+	if _, ok := c.members[c.self]; !ok || c.vnodes(c.self) == 0 {
+		return false, nil
+	}
+	if c.count() == 1 {
+		return true, nil
+	}
+
+	return c.lookup(key) == c.self, nil
+}
+
+// LocalRanges returns the ranges of hashes owned by the local node,
+// in ring order, merging the contiguous ones.
+// Returns nil if the local node is unknown or not a ring member.
+func (c *Consistent) LocalRanges() []Range {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, ok := c.members[c.self]; !ok || c.self == "" {
+		return nil
+	}
+
+	var ranges []Range
+	n := len(c.hashes)
+	for i, h := range c.hashes {
+		if c.ring[h] != c.self {
+			continue
+		}
+
+		// Keys from the previous hash up to this one
+		// belong to this hash ring position
+		start := c.hashes[(i-1+n)%n]
+		if l := len(ranges); l > 0 && ranges[l-1].End == start {
+			ranges[l-1].End = h
+			continue
+		}
+		ranges = append(ranges, Range{Start: start, End: h})
+	}
+
+	// Merge the last range with the first one if
+	// they are contiguous through the end of the ring
+	if l := len(ranges); l > 1 && ranges[l-1].End == ranges[0].Start {
+		ranges[0].Start = ranges[l-1].Start
+		ranges = ranges[:l-1]
+	}
+
+	return ranges
+}
+
+// inferSelf sets the local node from the given remote, if the ring
+// local node was not explicitly set and the remote knows about it.
+func (c *Consistent) inferSelf(r remote.Remoter) {
+	l, ok := r.(remote.LocalMemberer)
+	if !ok {
+		return
+	}
+
+	m, ok := l.LocalMember()
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.self == "" {
+		c.self = c.identity(m)
+	}
+}
@@ -0,0 +1,199 @@
+package consistent
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ka3de/consistent/pkg/remote"
+)
+
+type localMockRemoter struct {
+	*mockRemoter
+	local string
+}
+
+func (mr *localMockRemoter) LocalMember() (remote.Member, bool) {
+	return remote.Member{Name: mr.local}, mr.local != ""
+}
+
+func TestOwns(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		nSrvs   int
+		self    string
+		wantErr error
+	}{
+		{
+			name:    "should return no self error",
+			nSrvs:   3,
+			wantErr: ErrNoSelf,
+		},
+		{
+			name:    "should return no srvs error",
+			self:    "srv0",
+			wantErr: ErrNoSrvs,
+		},
+		{
+			name:  "should own every key as single member",
+			nSrvs: 1,
+			self:  "srv0",
+		},
+		{
+			name:  "should own no key when not a member",
+			nSrvs: 3,
+			self:  "srv9",
+		},
+		{
+			name:  "should own keys resolving to self",
+			nSrvs: 5,
+			self:  "srv2",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := newTestC(t, tc.nSrvs, WithSelf(tc.self))
+
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("key%d", i)
+
+				owns, err := c.Owns(key)
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("unexpected error. want: %v but got: %v", tc.wantErr, err)
+				}
+				if err != nil {
+					continue
+				}
+
+				srv, _ := c.Get(key)
+				if want := srv == tc.self; owns != want {
+					t.Fatalf("expected owns %q to be %v, but got %v", key, want, owns)
+				}
+				if isLocal := c.IsLocal(key); isLocal != owns {
+					t.Fatalf("expected is local %q to be %v, but got %v", key, owns, isLocal)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalRanges(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		nSrvs      int
+		self       string
+		wantRanges int
+	}{
+		{
+			name:  "should return no ranges without self",
+			nSrvs: 3,
+		},
+		{
+			name:  "should return no ranges when not a member",
+			nSrvs: 3,
+			self:  "srv9",
+		},
+		{
+			name:       "should return the whole ring as single member",
+			nSrvs:      1,
+			self:       "srv0",
+			wantRanges: 1,
+		},
+		{
+			name:  "should return the ranges owned by self",
+			nSrvs: 5,
+			self:  "srv3",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := newTestC(t, tc.nSrvs, WithSelf(tc.self))
+			ranges := c.LocalRanges()
+
+			if tc.wantRanges > 0 && len(ranges) != tc.wantRanges {
+				t.Fatalf("expected ranges len to be %d, but got %d", tc.wantRanges, len(ranges))
+			}
+			if tc.self == "" || tc.self == "srv9" {
+				if ranges != nil {
+					t.Fatalf("expected no ranges, but got %v", ranges)
+				}
+				return
+			}
+
+			// Every ring position must be within
+			// the local ranges only if owned by self
+			for _, h := range c.hashes {
+				for _, k := range []Hash{h, h - 1} {
+					in := false
+					for _, r := range ranges {
+						if r.Contains(k) {
+							in = true
+						}
+					}
+					if own := c.ring[c.hashes[c.search(k)]] == tc.self; in != own {
+						t.Fatalf("expected hash %d in local ranges to be %v, but got %v", k, own, in)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestInferSelf(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		opts     []opt
+		local    string
+		wantSelf string
+	}{
+		{
+			name:     "should infer self from remote",
+			local:    "srv1",
+			wantSelf: "srv1",
+		},
+		{
+			name:     "should not override explicit self",
+			opts:     []opt{WithSelf("srv0")},
+			local:    "srv1",
+			wantSelf: "srv0",
+		},
+		{
+			name:     "should map self through identity",
+			opts:     []opt{WithIdentity(func(m remote.Member) string { return "id-" + m.Name })},
+			local:    "srv1",
+			wantSelf: "id-srv1",
+		},
+		{
+			name: "should not infer unknown local member",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mr := &localMockRemoter{mockRemoter: newMockRemoter("srv0", "srv1"), local: tc.local}
+			c := NewConsistent(append(tc.opts, WithRemote(mr))...)
+			t.Cleanup(func() { c.Close() }) //nolint:errcheck
+
+			if self := c.Self(); self != tc.wantSelf {
+				t.Fatalf("expected self to be %q, but got %q", tc.wantSelf, self)
+			}
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package consistent
 
-import "github.com/ka3de/consistent/pkg/remote"
+import (
+	"log/slog"
+	"time"
+
+	"github.com/ka3de/consistent/pkg/remote"
+)
 
 type opt func(*Consistent)
 
@@ -21,3 +26,52 @@ func WithRemote(r remote.Remoter) opt {
 		c.remote = r
 	}
 }
+
+// WithReconcileInterval sets the interval on which the ring members
+// are reconciled against the full remote membership.
+// A zero interval disables the periodic reconciliation, although the
+// ring is still reconciled once when the remote handling starts.
+func WithReconcileInterval(d time.Duration) opt {
+	return func(c *Consistent) {
+		c.reconcileInterval = d
+	}
+}
+
+// WithMemberFilter sets the filter which decides which of the
+// remote members are part of the ring. By default all of them are.
+func WithMemberFilter(f MemberFilter) opt {
+	return func(c *Consistent) {
+		c.filter = f
+	}
+}
+
+// WithIdentity sets how the ring identity of the remote members is
+// derived. By default members are identified by their node name.
+func WithIdentity(id Identity) opt {
+	return func(c *Consistent) {
+		c.identity = id
+	}
+}
+
+// WithLogger sets the logger used by the ring. Defaults to slog.Default().
+func WithLogger(l *slog.Logger) opt {
+	return func(c *Consistent) {
+		c.logger = l
+	}
+}
+
+// WithErrorHandler sets a handler which is notified of every remote
+// event that could not be applied to the ring, along with its error.
+func WithErrorHandler(h func(remote.Event, error)) opt {
+	return func(c *Consistent) {
+		c.errHandler = h
+	}
+}
+
+// WithSelf sets the ring identity of the local node. If not set, it
+// is inferred from the remote when it is aware of the local node.
+func WithSelf(srv string) opt {
+	return func(c *Consistent) {
+		c.self = srv
+	}
+}
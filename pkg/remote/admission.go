@@ -0,0 +1,116 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+
+	"github.com/hashicorp/memberlist"
+)
+
+// ErrNodeRejected indicates that a node was not admitted
+// as a member of the cluster.
+var ErrNodeRejected = errors.New("node rejected")
+
+// gossipAdmission implements the memberlist.AliveDelegate and
+// memberlist.MergeDelegate interfaces in order to prevent nodes
+// which do not fulfill the admission rules from becoming members
+// of the cluster.
+type gossipAdmission struct {
+	g *Gossiper
+}
+
+// NotifyAlive is invoked when a message about a live node is
+// received. Returning an error prevents the node from being
+// considered a peer.
+func (a *gossipAdmission) NotifyAlive(peer *memberlist.Node) error {
+	return a.g.admit(peer)
+}
+
+// NotifyMerge is invoked when a merge could take place.
+// Returning an error cancels the whole merge.
+func (a *gossipAdmission) NotifyMerge(peers []*memberlist.Node) error {
+	for _, p := range peers {
+		if p.State == memberlist.StateDead || p.State == memberlist.StateLeft {
+			continue
+		}
+		if err := a.g.admit(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// admit verifies that the given node fulfills the admission rules:
+// it speaks a compatible metadata protocol, belongs to the same
+// cluster, and its name and address are allowed.
+func (g *Gossiper) admit(n *memberlist.Node) error {
+	if n.Name == g.name {
+		return nil
+	}
+
+	reject := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s: %s", ErrNodeRejected, n.Name, fmt.Sprintf(format, args...))
+	}
+
+	meta, err := decodeMeta(n.Meta)
+	if err != nil {
+		return reject("%v", err)
+	}
+	if meta.Version != metaVersion {
+		return reject("incompatible protocol version %d", meta.Version)
+	}
+	if meta.Cluster != g.config.ClusterName {
+		return reject("cluster name %q does not match %q", meta.Cluster, g.config.ClusterName)
+	}
+
+	if len(g.config.AllowedNames) > 0 && !matchAny(g.config.AllowedNames, n.Name) {
+		return reject("name not allowed")
+	}
+	if len(g.allowedCIDRs) > 0 && !containsAny(g.allowedCIDRs, n.Addr) {
+		return reject("address %s not allowed", n.Addr)
+	}
+
+	if g.config.Admit != nil {
+		if err := g.config.Admit(nodeToMember(n)); err != nil {
+			return reject("%v", err)
+		}
+	}
+
+	return nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsAny(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+		nets[i] = n
+	}
+
+	return nets, nil
+}
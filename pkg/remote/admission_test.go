@@ -0,0 +1,119 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGossiperAdmission(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		seed     func(*GossiperConfig)
+		joiner   func(*GossiperConfig)
+		joinerID string
+		// wantJoinErr is only expected when both nodes
+		// reject each other
+		wantJoinErr  bool
+		wantRejected bool
+	}{
+		{
+			name: "should admit node of the same cluster",
+			seed: func(c *GossiperConfig) {
+				c.ClusterName = "prod"
+			},
+			joiner: func(c *GossiperConfig) {
+				c.ClusterName = "prod"
+			},
+			joinerID: "node1",
+		},
+		{
+			name: "should reject node of a different cluster",
+			seed: func(c *GossiperConfig) {
+				c.ClusterName = "prod"
+			},
+			joiner: func(c *GossiperConfig) {
+				c.ClusterName = "staging"
+			},
+			joinerID:     "node1",
+			wantJoinErr:  true,
+			wantRejected: true,
+		},
+		{
+			name: "should reject node with a name not allowed",
+			seed: func(c *GossiperConfig) {
+				c.AllowedNames = []string{"storage-*"}
+			},
+			joiner:       func(c *GossiperConfig) {},
+			joinerID:     "proxy-1",
+			wantRejected: true,
+		},
+		{
+			name: "should admit node with an allowed name",
+			seed: func(c *GossiperConfig) {
+				c.AllowedNames = []string{"node*"}
+			},
+			joiner:   func(c *GossiperConfig) {},
+			joinerID: "node1",
+		},
+		{
+			name: "should reject node with an address not allowed",
+			seed: func(c *GossiperConfig) {
+				c.AllowedCIDRs = []string{"10.0.0.0/8"}
+			},
+			joiner:       func(c *GossiperConfig) {},
+			joinerID:     "node1",
+			wantRejected: true,
+		},
+		{
+			name: "should reject node by admission hook",
+			seed: func(c *GossiperConfig) {
+				c.Admit = func(m Member) error {
+					return errors.New("not today")
+				}
+			},
+			joiner:       func(c *GossiperConfig) {},
+			joinerID:     "node1",
+			wantRejected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			g0 := newTestGossiper(t, "node0", tc.seed)
+			g1 := newTestGossiper(t, tc.joinerID, tc.joiner)
+
+			_, err := g1.Join(context.Background(), []string{gossiperAddr(t, g0)})
+			if tc.wantJoinErr && err == nil {
+				t.Fatal("expected join to fail")
+			}
+			if !tc.wantJoinErr && err != nil {
+				t.Fatalf("unexpected error joining cluster: %v", err)
+			}
+
+			if !tc.wantRejected {
+				waitEvent(t, g0, EventJoin, tc.joinerID)
+				return
+			}
+
+			select {
+			case e := <-g0.EventsCh():
+				if e.Name == tc.joinerID {
+					t.Fatalf("expected rejected node not to be notified, but got event %v", e)
+				}
+			case <-time.After(500 * time.Millisecond):
+			}
+			for _, m := range g0.Members() {
+				if m.Name == tc.joinerID {
+					t.Fatal("expected rejected node not to be a member")
+				}
+			}
+		})
+	}
+}
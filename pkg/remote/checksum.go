@@ -0,0 +1,225 @@
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/memberlist"
+)
+
+const (
+	defChecksumInterval    = 5 * time.Second
+	defDivergenceThreshold = 30 * time.Second
+)
+
+// DivergenceConfig defines how the ring checksums published by the
+// members are gossiped and compared in order to detect members with
+// a divergent view of the ring.
+type DivergenceConfig struct {
+	// Interval is the interval on which the local checksum is
+	// broadcast and the checksums of the members are compared.
+	// Defaults to 5s.
+	Interval time.Duration
+	// Threshold is the time the checksum of a member has to differ
+	// from the local one before an EventDivergence is delivered for
+	// it. Defaults to 30s.
+	Threshold time.Duration
+}
+
+// checksumMsg is the message broadcast by every node announcing
+// the checksum of its view of the ring, along with the greatest
+// ring epoch it knows.
+type checksumMsg struct {
+	Node  string `json:"n"`
+	Sum   uint64 `json:"s"`
+	Epoch uint64 `json:"e,omitempty"`
+}
+
+// checksumBroadcast is a broadcast of the local checksum,
+// which invalidates any previous one pending to be sent.
+type checksumBroadcast struct {
+	msg []byte
+}
+
+func (b *checksumBroadcast) Invalidates(other memberlist.Broadcast) bool {
+	_, ok := other.(*checksumBroadcast)
+	return ok
+}
+
+func (b *checksumBroadcast) Message() []byte {
+	return b.msg
+}
+
+func (b *checksumBroadcast) Finished() {}
+
+// peerChecksum holds the last checksum received from a member.
+type peerChecksum struct {
+	sum        uint64
+	divergedAt time.Time // zero if it matches the local checksum
+	reported   bool
+}
+
+// checksums tracks the local checksum and the ones of the members,
+// and the greatest ring epoch published by any of them.
+type checksums struct {
+	mu        sync.Mutex
+	local     uint64
+	published bool
+	epoch     uint64
+	peers     map[string]*peerChecksum
+}
+
+// PublishChecksum sets the checksum of the local view of the ring,
+// which is broadcast to the cluster and compared with the ones
+// published by the rest of the members. Only the members publishing
+// a checksum are compared.
+func (g *Gossiper) PublishChecksum(sum uint64) {
+	cs := g.checksums
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.local = sum
+	cs.published = true
+	for _, p := range cs.peers {
+		cs.compare(p)
+	}
+
+	g.broadcastChecksumLocked()
+}
+
+// PublishEpoch sets the ring epoch of the local node, which is
+// gossiped along with the ring checksum, so it is only broadcast
+// once a checksum is published.
+func (g *Gossiper) PublishEpoch(epoch uint64) {
+	cs := g.checksums
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.epoch = max(cs.epoch, epoch)
+}
+
+// Epoch returns the greatest ring epoch published by any member,
+// including the local one.
+func (g *Gossiper) Epoch() uint64 {
+	cs := g.checksums
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.epoch
+}
+
+// broadcastChecksumLocked queues the broadcast of the local checksum.
+// Checksums lock must be held before calling this method.
+func (g *Gossiper) broadcastChecksumLocked() {
+	cs := g.checksums
+	if !cs.published {
+		return
+	}
+
+	payload, err := json.Marshal(checksumMsg{Node: g.name, Sum: cs.local, Epoch: cs.epoch})
+	if err != nil {
+		g.reportErr("error encoding checksum message", err)
+		return
+	}
+
+	g.queueBroadcast(&checksumBroadcast{msg: encodeMsg(msgChecksum, payload)})
+}
+
+// handleChecksumMsg records the checksum announced by a member,
+// notifying if it knows a greater ring epoch.
+func (g *Gossiper) handleChecksumMsg(payload []byte) error {
+	var msg checksumMsg
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return fmt.Errorf("error decoding checksum message: %w", err)
+	}
+	if msg.Node == g.name {
+		return nil
+	}
+
+	cs := g.checksums
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	p, ok := cs.peers[msg.Node]
+	if !ok {
+		p = &peerChecksum{}
+		cs.peers[msg.Node] = p
+	}
+	p.sum = msg.Sum
+	cs.compare(p)
+
+	if msg.Epoch > cs.epoch {
+		cs.epoch = msg.Epoch
+		g.events.push(Event{Typ: EventEpoch})
+	}
+
+	return nil
+}
+
+// compare updates the divergence state of the given member
+// comparing its checksum with the local one.
+// Checksums lock must be held before calling this method.
+func (cs *checksums) compare(p *peerChecksum) {
+	if !cs.published || p.sum == cs.local {
+		p.divergedAt = time.Time{}
+		p.reported = false
+		return
+	}
+	if p.divergedAt.IsZero() {
+		p.divergedAt = time.Now()
+	}
+}
+
+// checkDivergence periodically broadcasts the local checksum and
+// delivers an EventDivergence for every member whose checksum has
+// differed for longer than the divergence threshold, once per
+// divergence, until the Gossiper is shut down.
+func (g *Gossiper) checkDivergence() {
+	interval := g.config.Divergence.Interval
+	if interval <= 0 {
+		interval = defChecksumInterval
+	}
+	threshold := g.config.Divergence.Threshold
+	if threshold <= 0 {
+		threshold = defDivergenceThreshold
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-g.ctx.Done():
+			return
+		}
+
+		nodes := make(map[string]*memberlist.Node)
+		for _, n := range g.events.liveNodes() {
+			nodes[n.Name] = n
+		}
+
+		cs := g.checksums
+		cs.mu.Lock()
+		g.broadcastChecksumLocked()
+		for name, p := range cs.peers {
+			n, ok := nodes[name]
+			if !ok {
+				delete(cs.peers, name)
+				continue
+			}
+			if p.reported || p.divergedAt.IsZero() || time.Since(p.divergedAt) < threshold {
+				continue
+			}
+			p.reported = true
+			g.events.push(nodeToEvent(EventDivergence, n))
+		}
+		cs.mu.Unlock()
+	}
+}
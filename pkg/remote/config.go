@@ -0,0 +1,282 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"path"
+	"time"
+
+	"github.com/hashicorp/memberlist"
+)
+
+// ErrInvalidConfig indicates that the Gossiper config is not valid.
+var ErrInvalidConfig = errors.New("invalid gossiper config")
+
+const (
+	GossiperNetworkLocal GossiperNetwork = iota
+	GossiperNetworkLAN
+	GossiperNetworkWAN
+)
+
+// GossiperNetwork defines the base profile of the memberlist
+// configuration, tuned for the kind of network the cluster
+// operates in.
+type GossiperNetwork int
+
+// GossiperConfig represents the configuration of a Gossiper.
+//
+// Network selects the base profile, which the rest of the tuning
+// fields override. Zero values keep the value of the base profile.
+type GossiperConfig struct {
+	// NodeName is the unique name of the node in the cluster.
+	// Defaults to the hostname.
+	NodeName string
+	Network  GossiperNetwork
+	Port     int
+
+	// BindAddr is the address to bind to. Defaults to all interfaces.
+	BindAddr string
+	// AdvertiseAddr and AdvertisePort are the address and port
+	// advertised to other nodes, which can differ from the bound
+	// ones, e.g. behind a NAT. AdvertisePort defaults to Port.
+	AdvertiseAddr string
+	AdvertisePort int
+
+	// Transport, if set, is used to communicate with other nodes
+	// instead of binding UDP and TCP listeners, e.g. an in-memory
+	// transport in tests. A transport must not be reused after the
+	// Gossiper is shut down.
+	Transport memberlist.Transport
+
+	// Label is attached to every message, and messages with a
+	// different label are dropped, so clusters sharing a network
+	// do not talk to each other.
+	Label string
+
+	ProbeInterval           time.Duration
+	ProbeTimeout            time.Duration
+	GossipInterval          time.Duration
+	GossipNodes             int
+	PushPullInterval        time.Duration
+	TCPTimeout              time.Duration
+	IndirectChecks          int
+	RetransmitMult          int
+	SuspicionMult           int
+	SuspicionMaxTimeoutMult int
+
+	// Tags are announced to the rest of the cluster as part of
+	// the node metadata, e.g. the node role.
+	Tags map[string]string
+
+	// ClusterName identifies the cluster the node belongs to.
+	// Nodes announcing a different cluster name are rejected.
+	ClusterName string
+	// AllowedNames, if set, restricts the names of the nodes which
+	// are admitted to the cluster to the ones matching any of these
+	// patterns, using the path.Match syntax.
+	AllowedNames []string
+	// AllowedCIDRs, if set, restricts the addresses of the nodes
+	// which are admitted to the cluster.
+	AllowedCIDRs []string
+	// Admit, if set, is invoked for every node before it becomes a
+	// member of the cluster. Returning an error rejects the node.
+	Admit func(Member) error
+
+	// SecretKeys enables the encryption of the gossip traffic using
+	// AES-GCM. Each key must be 16, 24 or 32 bytes long, and the first
+	// one is used as primary key. Nodes with encryption enabled never
+	// accept plaintext messages.
+	SecretKeys [][]byte
+
+	// Logger is used by the Gossiper, including the memberlist
+	// internal logs. Defaults to slog.Default().
+	Logger *slog.Logger
+	// ErrorHandler, if set, is notified of the errors which happen
+	// in background, such as failures to re-join the cluster or to
+	// handle messages from other nodes.
+	ErrorHandler func(error)
+
+	// EventQueueSize is the maximum number of nodes with pending
+	// events waiting to be read from EventsCh. Zero means unbounded.
+	EventQueueSize int
+	// EventQueueOverflow defines which event is discarded when the
+	// events queue is full. Defaults to OverflowDropOldest.
+	EventQueueOverflow OverflowPolicy
+
+	// RingConfigTombstoneTTL is how long deleted ring config keys
+	// are remembered, so the deletion wins over older values still
+	// known by other nodes. A node which stays partitioned for
+	// longer may bring a deleted key back. Defaults to 24h.
+	RingConfigTombstoneTTL time.Duration
+
+	// Join defines the strategy used to join the cluster.
+	Join JoinConfig
+
+	// Divergence defines how divergent views of the ring are detected.
+	Divergence DivergenceConfig
+}
+
+// Validate verifies that the config values are valid.
+// Returns an error wrapping ErrInvalidConfig if they are not.
+func (c GossiperConfig) Validate() error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, fmt.Sprintf(format, args...))
+	}
+
+	switch c.Network {
+	case GossiperNetworkLocal, GossiperNetworkLAN, GossiperNetworkWAN:
+	default:
+		return invalid("unknown network %d", c.Network)
+	}
+
+	if c.Port < 0 || c.Port > 65535 {
+		return invalid("port %d out of range", c.Port)
+	}
+	if c.AdvertisePort < 0 || c.AdvertisePort > 65535 {
+		return invalid("advertise port %d out of range", c.AdvertisePort)
+	}
+	if c.BindAddr != "" && net.ParseIP(c.BindAddr) == nil {
+		return invalid("bind address %q is not an IP address", c.BindAddr)
+	}
+	if c.AdvertiseAddr != "" && net.ParseIP(c.AdvertiseAddr) == nil {
+		return invalid("advertise address %q is not an IP address", c.AdvertiseAddr)
+	}
+	if len(c.Label) > memberlist.LabelMaxSize {
+		return invalid("label exceeds %d bytes", memberlist.LabelMaxSize)
+	}
+
+	meta := nodeMeta{
+		Version:     metaVersion,
+		Cluster:     c.ClusterName,
+		Tags:        c.Tags,
+		Incarnation: math.MaxUint64,
+	}
+	if err := validateMetaSize(meta); err != nil {
+		return invalid("%v", err)
+	}
+
+	for _, p := range c.AllowedNames {
+		if _, err := path.Match(p, ""); err != nil {
+			return invalid("allowed name pattern %q: %v", p, err)
+		}
+	}
+	if _, err := parseCIDRs(c.AllowedCIDRs); err != nil {
+		return invalid("allowed CIDRs: %v", err)
+	}
+
+	for i, k := range c.SecretKeys {
+		if err := memberlist.ValidateKey(k); err != nil {
+			return invalid("secret key %d: %v", i, err)
+		}
+	}
+
+	for name, d := range map[string]time.Duration{
+		"probe interval":       c.ProbeInterval,
+		"probe timeout":        c.ProbeTimeout,
+		"gossip interval":      c.GossipInterval,
+		"push/pull interval":   c.PushPullInterval,
+		"TCP timeout":          c.TCPTimeout,
+		"join deadline":        c.Join.Deadline,
+		"join backoff":         c.Join.InitialBackoff,
+		"join max backoff":     c.Join.MaxBackoff,
+		"join rejoin interval": c.Join.RejoinInterval,
+		"divergence interval":  c.Divergence.Interval,
+		"divergence threshold": c.Divergence.Threshold,
+		"ring config TTL":      c.RingConfigTombstoneTTL,
+	} {
+		if d < 0 {
+			return invalid("negative %s %v", name, d)
+		}
+	}
+
+	for name, n := range map[string]int{
+		"gossip nodes":               c.GossipNodes,
+		"indirect checks":            c.IndirectChecks,
+		"retransmit multiplier":      c.RetransmitMult,
+		"suspicion multiplier":       c.SuspicionMult,
+		"suspicion max timeout mult": c.SuspicionMaxTimeoutMult,
+		"event queue size":           c.EventQueueSize,
+		"join min members":           c.Join.MinMembers,
+	} {
+		if n < 0 {
+			return invalid("negative %s %d", name, n)
+		}
+	}
+
+	mlConfig := c.memberlistConfig()
+	if mlConfig.ProbeTimeout >= mlConfig.ProbeInterval {
+		return invalid("probe timeout %v must be lower than probe interval %v",
+			mlConfig.ProbeTimeout, mlConfig.ProbeInterval)
+	}
+
+	switch c.EventQueueOverflow {
+	case OverflowDropOldest, OverflowDropNewest:
+	default:
+		return invalid("unknown event queue overflow policy %d", c.EventQueueOverflow)
+	}
+
+	return nil
+}
+
+// memberlistConfig builds the memberlist config from the base
+// profile defined by the network, overriding it with the non
+// zero tuning values.
+func (c GossiperConfig) memberlistConfig() *memberlist.Config {
+	var mlConfig *memberlist.Config
+	switch c.Network {
+	case GossiperNetworkWAN:
+		mlConfig = memberlist.DefaultWANConfig()
+	case GossiperNetworkLocal:
+		mlConfig = memberlist.DefaultLocalConfig()
+	default:
+		mlConfig = memberlist.DefaultLANConfig()
+	}
+
+	if c.NodeName != "" {
+		mlConfig.Name = c.NodeName
+	}
+	mlConfig.BindPort = c.Port
+	mlConfig.AdvertisePort = c.Port
+
+	if c.BindAddr != "" {
+		mlConfig.BindAddr = c.BindAddr
+	}
+	if c.AdvertiseAddr != "" {
+		mlConfig.AdvertiseAddr = c.AdvertiseAddr
+	}
+	if c.AdvertisePort != 0 {
+		mlConfig.AdvertisePort = c.AdvertisePort
+	}
+	if c.Label != "" {
+		mlConfig.Label = c.Label
+	}
+	mlConfig.Transport = c.Transport
+
+	overrideDuration(&mlConfig.ProbeInterval, c.ProbeInterval)
+	overrideDuration(&mlConfig.ProbeTimeout, c.ProbeTimeout)
+	overrideDuration(&mlConfig.GossipInterval, c.GossipInterval)
+	overrideDuration(&mlConfig.PushPullInterval, c.PushPullInterval)
+	overrideDuration(&mlConfig.TCPTimeout, c.TCPTimeout)
+	overrideInt(&mlConfig.GossipNodes, c.GossipNodes)
+	overrideInt(&mlConfig.IndirectChecks, c.IndirectChecks)
+	overrideInt(&mlConfig.RetransmitMult, c.RetransmitMult)
+	overrideInt(&mlConfig.SuspicionMult, c.SuspicionMult)
+	overrideInt(&mlConfig.SuspicionMaxTimeoutMult, c.SuspicionMaxTimeoutMult)
+
+	return mlConfig
+}
+
+func overrideDuration(dst *time.Duration, v time.Duration) {
+	if v != 0 {
+		*dst = v
+	}
+}
+
+func overrideInt(dst *int, v int) {
+	if v != 0 {
+		*dst = v
+	}
+}
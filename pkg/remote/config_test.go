@@ -0,0 +1,96 @@
+package remote
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGossiperConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		config  GossiperConfig
+		wantErr error
+	}{
+		{
+			name:   "should accept default config",
+			config: GossiperConfig{},
+		},
+		{
+			name: "should accept tuned config",
+			config: GossiperConfig{
+				Network:       GossiperNetworkWAN,
+				Port:          7946,
+				BindAddr:      "0.0.0.0",
+				AdvertiseAddr: "10.0.0.1",
+				AdvertisePort: 8946,
+				Label:         "prod",
+				ProbeInterval: 5 * time.Second,
+				SuspicionMult: 6,
+			},
+		},
+		{
+			name:    "should return error invalid network",
+			config:  GossiperConfig{Network: 10},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "should return error port out of range",
+			config:  GossiperConfig{Port: 70000},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "should return error invalid bind address",
+			config:  GossiperConfig{BindAddr: "not-an-ip"},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "should return error negative duration",
+			config:  GossiperConfig{GossipInterval: -time.Second},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "should return error probe timeout over base profile interval",
+			config:  GossiperConfig{Network: GossiperNetworkLAN, ProbeTimeout: 5 * time.Second},
+			wantErr: ErrInvalidConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tc.config.Validate(); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("unexpected error. want: %v but got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGossiperConfigOverrides(t *testing.T) {
+	t.Parallel()
+
+	c := GossiperConfig{
+		Network:       GossiperNetworkLAN,
+		Port:          7946,
+		AdvertisePort: 8946,
+		GossipNodes:   5,
+	}
+
+	mlConfig := c.memberlistConfig()
+	if mlConfig.BindPort != 7946 {
+		t.Fatalf("expected bind port to be %d, but got %d", 7946, mlConfig.BindPort)
+	}
+	if mlConfig.AdvertisePort != 8946 {
+		t.Fatalf("expected advertise port to be %d, but got %d", 8946, mlConfig.AdvertisePort)
+	}
+	if mlConfig.GossipNodes != 5 {
+		t.Fatalf("expected gossip nodes to be %d, but got %d", 5, mlConfig.GossipNodes)
+	}
+	if mlConfig.ProbeInterval != time.Second {
+		t.Fatalf("expected probe interval to keep LAN value %v, but got %v", time.Second, mlConfig.ProbeInterval)
+	}
+}
@@ -0,0 +1,116 @@
+package remote
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/memberlist"
+)
+
+const (
+	msgKeyring msgType = iota
+	msgChecksum
+	msgRingConfig
+	msgUser
+)
+
+// msgType identifies the kind of user message sent between
+// Gossipers, which is prepended to the message payload.
+type msgType uint8
+
+// gossipDelegate implements the memberlist.Delegate interface
+// in order to exchange user messages between Gossipers.
+type gossipDelegate struct {
+	g *Gossiper
+}
+
+// NodeMeta is used to retrieve meta-data about the current node
+// when broadcasting an alive message.
+func (d *gossipDelegate) NodeMeta(limit int) []byte {
+	buf, err := encodeMeta(d.g.localMeta())
+	if err != nil {
+		d.g.reportErr("error building node metadata", err)
+		return nil
+	}
+	if len(buf) > limit {
+		d.g.reportErr("error building node metadata", fmt.Errorf("%d bytes exceed limit of %d", len(buf), limit))
+		return nil
+	}
+
+	return buf
+}
+
+// NotifyMsg is called when a user-data message is received.
+// It must not block, and the given buffer must be copied
+// if it is kept after the call returns.
+func (d *gossipDelegate) NotifyMsg(buf []byte) {
+	if len(buf) == 0 {
+		return
+	}
+
+	var err error
+	switch msgType(buf[0]) {
+	case msgKeyring:
+		err = d.g.handleKeyringMsg(buf[1:])
+	case msgChecksum:
+		err = d.g.handleChecksumMsg(buf[1:])
+	case msgRingConfig:
+		err = d.g.handleRingConfigMsg(buf[1:])
+	case msgUser:
+		d.g.handleUserMsg(buf[1:])
+	default:
+		err = fmt.Errorf("unknown message type: %d", buf[0])
+	}
+
+	if err != nil {
+		d.g.reportErr("error handling message", err)
+	}
+}
+
+// GetBroadcasts is called when user data messages can be broadcast.
+func (d *gossipDelegate) GetBroadcasts(overhead, limit int) [][]byte {
+	q := d.g.broadcasts.Load()
+	if q == nil {
+		return nil
+	}
+
+	return q.GetBroadcasts(overhead, limit)
+}
+
+// LocalState is used for a TCP Push/Pull, sending the
+// full ring config to the remote node.
+func (d *gossipDelegate) LocalState(join bool) []byte {
+	buf, err := d.g.ringConfig.encode()
+	if err != nil {
+		d.g.reportErr("error encoding ring config", err)
+		return nil
+	}
+
+	return buf
+}
+
+// MergeRemoteState is invoked after a TCP Push/Pull,
+// merging the ring config of the remote node.
+func (d *gossipDelegate) MergeRemoteState(buf []byte, join bool) {
+	if len(buf) == 0 {
+		return
+	}
+
+	if err := d.g.handleRingConfigMsg(buf); err != nil {
+		d.g.reportErr("error merging remote state", err)
+	}
+}
+
+// queueBroadcast queues the given broadcast to be sent to the
+// cluster. Broadcasts are dropped if the Gossiper is not running.
+func (g *Gossiper) queueBroadcast(b memberlist.Broadcast) {
+	if q := g.broadcasts.Load(); q != nil {
+		q.QueueBroadcast(b)
+	}
+}
+
+// encodeMsg prepends the given message type to the payload.
+func encodeMsg(typ msgType, payload []byte) []byte {
+	buf := make([]byte, 0, len(payload)+1)
+	buf = append(buf, byte(typ))
+	return append(buf, payload...)
+}
@@ -0,0 +1,399 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	defDNSMinRefresh = 5 * time.Second
+	defDNSMaxRefresh = 5 * time.Minute
+	defDNSTimeout    = 5 * time.Second
+	defResolvConf    = "/etc/resolv.conf"
+	// dnsUDPSize is the UDP payload size advertised with EDNS0.
+	dnsUDPSize = 4096
+	// maxCNAMEs is the maximum length of the CNAME
+	// chains followed in the answer of a query.
+	maxCNAMEs = 8
+)
+
+const (
+	// DNSRecordA resolves the A and AAAA records of the name, being
+	// every address a member named after its host:port.
+	DNSRecordA DNSRecordType = iota
+	// DNSRecordSRV resolves the SRV records of the name, being every
+	// target a member named after its target:port. Only the records
+	// with the lowest priority are used, as the others are fallbacks,
+	// and their weights are used as the members weight, scaled so the
+	// greatest one is 1.
+	DNSRecordSRV
+)
+
+// DNSRecordType is the type of DNS records resolved by a DNSRemoter.
+type DNSRecordType int
+
+// ErrDNSQuery indicates that a DNS query failed.
+var ErrDNSQuery = errors.New("DNS query failed")
+
+// DNSRemoterConfig defines the DNS name a DNSRemoter resolves the
+// membership from and how often it is refreshed.
+type DNSRemoterConfig struct {
+	Name string
+	Type DNSRecordType
+	// Port is the port of the members for A and AAAA records.
+	Port uint16
+
+	// Server is the host:port of the DNS server to query.
+	// Defaults to the first one in /etc/resolv.conf.
+	Server string
+	// Timeout is the timeout of every query. Defaults to 5s.
+	Timeout time.Duration
+
+	// The membership is refreshed once the TTL of the resolved records
+	// expires, bounded by MinRefresh and MaxRefresh, which default to
+	// 5s and 5m. Failed refreshes are retried after MinRefresh.
+	MinRefresh time.Duration
+	MaxRefresh time.Duration
+
+	// Logger is used by the DNSRemoter. Defaults to slog.Default().
+	Logger *slog.Logger
+	// ErrorHandler, if set, is notified of the errors
+	// refreshing the membership in background.
+	ErrorHandler func(error)
+}
+
+// DNSRemoter is a Remoter which resolves the membership from DNS
+// records, delivering the differences between consecutive refreshes
+// as events. If a refresh fails, including when the name resolves to
+// no members, the last known membership is kept.
+type DNSRemoter struct {
+	*memberSet
+
+	config    DNSRemoterConfig
+	logger    *slog.Logger
+	client    *dns.Client
+	tcpClient *dns.Client
+}
+
+// NewDNSRemoter creates a new DNSRemoter for the given config, and
+// resolves the membership for the first time, returning an error if
+// it fails. The initial members are not delivered as events, but
+// returned by Members. The DNSRemoter must be closed once done.
+func NewDNSRemoter(ctx context.Context, config DNSRemoterConfig) (*DNSRemoter, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("%w: empty DNS name", ErrInvalidConfig)
+	}
+	switch config.Type {
+	case DNSRecordA, DNSRecordSRV:
+	default:
+		return nil, fmt.Errorf("%w: unknown DNS record type %d", ErrInvalidConfig, config.Type)
+	}
+	if config.MinRefresh < 0 || config.MaxRefresh < 0 || config.Timeout < 0 {
+		return nil, fmt.Errorf("%w: negative DNS durations", ErrInvalidConfig)
+	}
+
+	if config.Server == "" {
+		cc, err := dns.ClientConfigFromFile(defResolvConf)
+		if err != nil || len(cc.Servers) == 0 {
+			return nil, fmt.Errorf("%w: no DNS server configured: %v", ErrInvalidConfig, err)
+		}
+		config.Server = net.JoinHostPort(cc.Servers[0], cc.Port)
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defDNSTimeout
+	}
+	if config.MinRefresh == 0 {
+		config.MinRefresh = defDNSMinRefresh
+	}
+	if config.MaxRefresh == 0 {
+		config.MaxRefresh = defDNSMaxRefresh
+	}
+	if config.MaxRefresh < config.MinRefresh {
+		return nil, fmt.Errorf("%w: max refresh %v is lower than min refresh %v",
+			ErrInvalidConfig, config.MaxRefresh, config.MinRefresh)
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	dr := &DNSRemoter{
+		config:    config,
+		logger:    logger,
+		client:    &dns.Client{Timeout: config.Timeout},
+		tcpClient: &dns.Client{Net: "tcp", Timeout: config.Timeout},
+	}
+
+	members, ttl, err := dr.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dr.memberSet = newMemberSet(members)
+
+	dr.wg.Add(1)
+	go dr.watch(ttl)
+
+	return dr, nil
+}
+
+// Refresh resolves the membership and delivers its changes.
+func (dr *DNSRemoter) Refresh(ctx context.Context) error {
+	_, err := dr.refresh(ctx)
+	return err
+}
+
+func (dr *DNSRemoter) refresh(ctx context.Context) (time.Duration, error) {
+	members, ttl, err := dr.resolve(ctx)
+	if err != nil {
+		return 0, err
+	}
+	dr.memberSet.update(members)
+
+	return ttl, nil
+}
+
+// Close stops refreshing the membership and closes the events
+// channel. Close is safe to be called multiple times.
+func (dr *DNSRemoter) Close() error {
+	dr.memberSet.close()
+	return nil
+}
+
+// watch refreshes the membership once the TTL of the
+// records expires until the DNSRemoter is closed.
+func (dr *DNSRemoter) watch(ttl time.Duration) {
+	defer dr.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-dr.done
+		cancel()
+	}()
+
+	for {
+		timer := time.NewTimer(dr.refreshAfter(ttl))
+		select {
+		case <-timer.C:
+		case <-dr.done:
+			timer.Stop()
+			return
+		}
+
+		var err error
+		if ttl, err = dr.refresh(ctx); err != nil && ctx.Err() == nil {
+			dr.logger.Error("error refreshing DNS membership", "name", dr.config.Name, "error", err)
+			if dr.config.ErrorHandler != nil {
+				dr.config.ErrorHandler(err)
+			}
+		}
+	}
+}
+
+// refreshAfter bounds the given TTL by the refresh limits.
+func (dr *DNSRemoter) refreshAfter(ttl time.Duration) time.Duration {
+	return min(max(ttl, dr.config.MinRefresh), dr.config.MaxRefresh)
+}
+
+// resolve resolves the members, returning
+// the minimum TTL of the resolved records.
+// An empty membership is returned as an error, as it is
+// more likely a misconfigured name than an empty cluster.
+func (dr *DNSRemoter) resolve(ctx context.Context) (map[string]Member, time.Duration, error) {
+	resolve := dr.resolveA
+	if dr.config.Type == DNSRecordSRV {
+		resolve = dr.resolveSRV
+	}
+
+	members, ttl, err := resolve(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(members) == 0 {
+		return nil, 0, fmt.Errorf("%w: %s: no members resolved", ErrDNSQuery, dr.config.Name)
+	}
+
+	return members, ttl, nil
+}
+
+func (dr *DNSRemoter) resolveA(ctx context.Context) (map[string]Member, time.Duration, error) {
+	ips, ttl, err := dr.resolveAddrs(ctx, dr.config.Name, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	members := make(map[string]Member, len(ips))
+	for _, ip := range ips {
+		name := net.JoinHostPort(ip.String(), strconv.Itoa(int(dr.config.Port)))
+		members[name] = Member{Name: name, Addr: ip, Port: dr.config.Port}
+	}
+
+	return members, ttl, nil
+}
+
+func (dr *DNSRemoter) resolveSRV(ctx context.Context) (map[string]Member, time.Duration, error) {
+	resp, err := dr.query(ctx, dr.config.Name, dns.TypeSRV)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var srvs []*dns.SRV
+	ttl := time.Duration(-1)
+	owner := followCNAMEs(dns.Fqdn(dr.config.Name), resp.Answer)
+	for _, rr := range resp.Answer {
+		srv, ok := rr.(*dns.SRV)
+		if !ok || !strings.EqualFold(rr.Header().Name, owner) {
+			continue
+		}
+		ttl = minTTL(ttl, rrTTL(rr))
+		if len(srvs) > 0 && srv.Priority > srvs[0].Priority {
+			continue
+		}
+		if len(srvs) > 0 && srv.Priority < srvs[0].Priority {
+			srvs = srvs[:0]
+		}
+		srvs = append(srvs, srv)
+	}
+
+	var maxWeight uint16 = 1
+	for _, srv := range srvs {
+		maxWeight = max(maxWeight, srv.Weight)
+	}
+
+	members := make(map[string]Member, len(srvs))
+	for _, srv := range srvs {
+		// Addresses are usually sent as additional records,
+		// otherwise they are resolved for every target
+		ips, addrTTL, err := dr.resolveAddrs(ctx, srv.Target, resp.Extra)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(ips) == 0 {
+			continue
+		}
+		ttl = minTTL(ttl, addrTTL)
+
+		target := strings.TrimSuffix(srv.Target, ".")
+		name := net.JoinHostPort(target, strconv.Itoa(int(srv.Port)))
+		members[name] = Member{
+			Name:   name,
+			Addr:   ips[0],
+			Port:   srv.Port,
+			Weight: float64(max(srv.Weight, 1)) / float64(maxWeight),
+		}
+	}
+
+	return members, max(ttl, 0), nil
+}
+
+// resolveAddrs resolves the A and AAAA records of the given name,
+// looking for them in the given records before querying them.
+func (dr *DNSRemoter) resolveAddrs(ctx context.Context, name string, known []dns.RR) ([]net.IP, time.Duration, error) {
+	fqdn := dns.Fqdn(name)
+
+	ips, ttl := addrRecords(fqdn, known)
+	if len(ips) > 0 {
+		return ips, ttl, nil
+	}
+
+	for _, typ := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		resp, err := dr.query(ctx, fqdn, typ)
+		if err != nil {
+			return nil, 0, err
+		}
+		typIPs, typTTL := addrRecords(fqdn, resp.Answer)
+		ips = append(ips, typIPs...)
+		ttl = minTTL(ttl, typTTL)
+	}
+
+	return ips, max(ttl, 0), nil
+}
+
+// query queries the given name and type, retrying over TCP
+// if the answer does not fit in a UDP message.
+func (dr *DNSRemoter) query(ctx context.Context, name string, typ uint16) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), typ)
+	msg.SetEdns0(dnsUDPSize, false)
+
+	resp, _, err := dr.client.ExchangeContext(ctx, msg, dr.config.Server)
+	if err == nil && resp.Truncated {
+		resp, _, err = dr.tcpClient.ExchangeContext(ctx, msg, dr.config.Server)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %s: %v", ErrDNSQuery, dns.TypeToString[typ], name, err)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("%w: %s %s: %s", ErrDNSQuery, dns.TypeToString[typ], name, dns.RcodeToString[resp.Rcode])
+	}
+
+	return resp, nil
+}
+
+// addrRecords returns the addresses of the A and AAAA records of
+// the given name, following its CNAMEs, and their minimum TTL, or
+// -1 if there are none.
+func addrRecords(fqdn string, rrs []dns.RR) ([]net.IP, time.Duration) {
+	fqdn = followCNAMEs(fqdn, rrs)
+
+	var ips []net.IP
+	ttl := time.Duration(-1)
+	for _, rr := range rrs {
+		if !strings.EqualFold(rr.Header().Name, fqdn) {
+			continue
+		}
+		switch r := rr.(type) {
+		case *dns.A:
+			ips = append(ips, r.A)
+		case *dns.AAAA:
+			ips = append(ips, r.AAAA)
+		default:
+			continue
+		}
+		ttl = minTTL(ttl, rrTTL(rr))
+	}
+
+	return ips, ttl
+}
+
+// followCNAMEs returns the name the given one is an alias of,
+// following the chain of CNAME records among the given ones.
+func followCNAMEs(fqdn string, rrs []dns.RR) string {
+	for i := 0; i < maxCNAMEs; i++ {
+		target := ""
+		for _, rr := range rrs {
+			if c, ok := rr.(*dns.CNAME); ok && strings.EqualFold(c.Hdr.Name, fqdn) {
+				target = c.Target
+				break
+			}
+		}
+		if target == "" {
+			break
+		}
+		fqdn = target
+	}
+
+	return fqdn
+}
+
+func rrTTL(rr dns.RR) time.Duration {
+	return time.Duration(rr.Header().Ttl) * time.Second
+}
+
+// minTTL returns the minimum of the given TTLs,
+// ignoring the ones which are unknown, being -1.
+func minTTL(a, b time.Duration) time.Duration {
+	if a < 0 || (b >= 0 && b < a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,377 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// stubDNS is a DNS server answering with the records it is set,
+// over UDP and TCP on the same port. UDP answers are truncated to
+// the size advertised by the client, as real servers do.
+type stubDNS struct {
+	addr string
+
+	mu         sync.Mutex
+	records    map[uint16][]string
+	rcode      int
+	truncate   bool
+	tcpQueries int
+}
+
+// newStubDNS starts a stub DNS server on a random local port,
+// answering with the given records, in zone file format.
+func newStubDNS(t *testing.T, records ...string) *stubDNS {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close() //nolint:errcheck
+		t.Fatalf("error listening: %v", err)
+	}
+
+	s := &stubDNS{addr: pc.LocalAddr().String()}
+	s.set(t, records...)
+
+	for _, srv := range []*dns.Server{{PacketConn: pc}, {Listener: l}} {
+		srv := srv
+		started := make(chan struct{})
+		srv.Handler = dns.HandlerFunc(s.serve)
+		srv.NotifyStartedFunc = func() { close(started) }
+		go srv.ActivateAndServe() //nolint:errcheck
+		<-started
+		t.Cleanup(func() { srv.Shutdown() }) //nolint:errcheck
+	}
+
+	return s
+}
+
+// set replaces the records of the server.
+func (s *stubDNS) set(t *testing.T, records ...string) {
+	t.Helper()
+
+	byType := make(map[uint16][]string)
+	for _, r := range records {
+		rr, err := dns.NewRR(r)
+		if err != nil {
+			t.Fatalf("error parsing record %q: %v", r, err)
+		}
+		byType[rr.Header().Rrtype] = append(byType[rr.Header().Rrtype], r)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = byType
+	s.rcode = dns.RcodeSuccess
+}
+
+func (s *stubDNS) fail(rcode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rcode = rcode
+}
+
+// truncateUDP makes the server truncate every UDP answer,
+// so they have to be queried over TCP.
+func (s *stubDNS) truncateUDP() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.truncate = true
+}
+
+func (s *stubDNS) queriesTCP() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tcpQueries
+}
+
+func (s *stubDNS) serve(w dns.ResponseWriter, req *dns.Msg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := new(dns.Msg)
+	resp.SetRcode(req, s.rcode)
+
+	q := req.Question[0]
+	name := q.Name
+	for _, r := range s.records[dns.TypeCNAME] {
+		rr, _ := dns.NewRR(r)
+		if rr.Header().Name == name {
+			resp.Answer = append(resp.Answer, rr)
+			name = rr.(*dns.CNAME).Target
+		}
+	}
+	for _, r := range s.records[q.Qtype] {
+		rr, _ := dns.NewRR(r)
+		if rr.Header().Name == name {
+			resp.Answer = append(resp.Answer, rr)
+		}
+	}
+
+	if _, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+		s.tcpQueries++
+	} else if s.truncate {
+		resp.Answer = nil
+		resp.Truncated = true
+	} else {
+		size := dns.MinMsgSize
+		if opt := req.IsEdns0(); opt != nil {
+			size = int(opt.UDPSize())
+		}
+		resp.Truncate(size)
+	}
+
+	w.WriteMsg(resp) //nolint:errcheck
+}
+
+func TestDNSRemoterResolve(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		typ         DNSRecordType
+		records     []string
+		wantMembers []Member
+		wantTTL     time.Duration
+		wantErr     error
+	}{
+		{
+			name: "should resolve A and AAAA records",
+			typ:  DNSRecordA,
+			records: []string{
+				"nodes.test. 30 IN A 10.0.0.1",
+				"nodes.test. 60 IN AAAA ::1",
+			},
+			wantMembers: []Member{
+				{Name: "10.0.0.1:7946", Addr: net.ParseIP("10.0.0.1").To4(), Port: 7946},
+				{Name: "[::1]:7946", Addr: net.ParseIP("::1"), Port: 7946},
+			},
+			wantTTL: 30 * time.Second,
+		},
+		{
+			name: "should resolve SRV records with lowest priority",
+			typ:  DNSRecordSRV,
+			records: []string{
+				"nodes.test. 60 IN SRV 10 20 7001 node0.test.",
+				"nodes.test. 60 IN SRV 10 10 7002 node1.test.",
+				"nodes.test. 60 IN SRV 20 10 7003 node2.test.",
+				"node0.test. 10 IN A 10.0.0.1",
+				"node1.test. 60 IN A 10.0.0.2",
+				"node2.test. 60 IN A 10.0.0.3",
+			},
+			wantMembers: []Member{
+				{Name: "node0.test:7001", Addr: net.ParseIP("10.0.0.1").To4(), Port: 7001, Weight: 1},
+				{Name: "node1.test:7002", Addr: net.ParseIP("10.0.0.2").To4(), Port: 7002, Weight: 0.5},
+			},
+			wantTTL: 10 * time.Second,
+		},
+		{
+			name: "should follow CNAME records",
+			typ:  DNSRecordA,
+			records: []string{
+				"nodes.test. 60 IN CNAME nodes.alias.test.",
+				"nodes.alias.test. 30 IN A 10.0.0.1",
+			},
+			wantMembers: []Member{
+				{Name: "10.0.0.1:7946", Addr: net.ParseIP("10.0.0.1").To4(), Port: 7946},
+			},
+			wantTTL: 30 * time.Second,
+		},
+		{
+			name: "should follow CNAME records of SRV targets",
+			typ:  DNSRecordSRV,
+			records: []string{
+				"nodes.test. 60 IN SRV 10 10 7001 node0.test.",
+				"node0.test. 60 IN CNAME node0.alias.test.",
+				"node0.alias.test. 10 IN A 10.0.0.1",
+			},
+			wantMembers: []Member{
+				{Name: "node0.test:7001", Addr: net.ParseIP("10.0.0.1").To4(), Port: 7001, Weight: 1},
+			},
+			wantTTL: 10 * time.Second,
+		},
+		{
+			name:    "should return error resolving no members",
+			typ:     DNSRecordA,
+			wantErr: ErrDNSQuery,
+		},
+		{
+			name:    "should return error resolving CNAME without addresses",
+			typ:     DNSRecordA,
+			records: []string{"nodes.test. 60 IN CNAME nodes.alias.test."},
+			wantErr: ErrDNSQuery,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newStubDNS(t, tc.records...)
+			dr, err := NewDNSRemoter(context.Background(), DNSRemoterConfig{
+				Name:   "nodes.test",
+				Type:   tc.typ,
+				Port:   7946,
+				Server: s.addr,
+			})
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("unexpected error. want: %v but got: %v", tc.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { dr.Close() }) //nolint:errcheck
+
+			if members := dr.Members(); !reflect.DeepEqual(members, tc.wantMembers) {
+				t.Fatalf("expected members to be %v, but got %v", tc.wantMembers, members)
+			}
+
+			_, ttl, err := dr.resolve(context.Background())
+			if err != nil {
+				t.Fatalf("error resolving: %v", err)
+			}
+			if ttl != tc.wantTTL {
+				t.Fatalf("expected ttl to be %v, but got %v", tc.wantTTL, ttl)
+			}
+		})
+	}
+}
+
+func TestDNSRemoterRefresh(t *testing.T) {
+	t.Parallel()
+
+	s := newStubDNS(t, "nodes.test. 0 IN A 10.0.0.1", "nodes.test. 0 IN A 10.0.0.2")
+
+	errs := make(chan error, 10)
+	dr, err := NewDNSRemoter(context.Background(), DNSRemoterConfig{
+		Name:         "nodes.test",
+		Port:         7946,
+		Server:       s.addr,
+		MinRefresh:   10 * time.Millisecond,
+		ErrorHandler: func(err error) { errs <- err },
+	})
+	if err != nil {
+		t.Fatalf("error creating DNS remoter: %v", err)
+	}
+	t.Cleanup(func() { dr.Close() }) //nolint:errcheck
+
+	s.set(t, "nodes.test. 0 IN A 10.0.0.2", "nodes.test. 0 IN A 10.0.0.3")
+	waitEvents(t, dr.EventsCh(),
+		Event{Typ: EventLeave, Name: "10.0.0.1:7946"},
+		Event{Typ: EventJoin, Name: "10.0.0.3:7946"},
+	)
+
+	// Failed refreshes keep the last known membership
+	s.fail(dns.RcodeServerFailure)
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrDNSQuery) {
+			t.Fatalf("unexpected error. want: %v but got: %v", ErrDNSQuery, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for error")
+	}
+	if members := dr.Members(); len(members) != 2 {
+		t.Fatalf("expected last known members to be kept, but got %v", members)
+	}
+
+	// Empty answers keep the last known membership too
+	s.set(t)
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrDNSQuery) {
+			t.Fatalf("unexpected error. want: %v but got: %v", ErrDNSQuery, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for error")
+	}
+	if members := dr.Members(); len(members) != 2 {
+		t.Fatalf("expected last known members to be kept, but got %v", members)
+	}
+}
+
+func TestDNSRemoterTruncated(t *testing.T) {
+	t.Parallel()
+
+	// The answer does not fit in a UDP message without EDNS0
+	var records []string
+	for i := 1; i <= 50; i++ {
+		records = append(records, fmt.Sprintf("nodes.test. 60 IN A 10.0.0.%d", i))
+	}
+
+	testCases := []struct {
+		name        string
+		truncateUDP bool
+		wantTCP     bool
+	}{
+		{
+			name: "should resolve large answers over UDP with EDNS0",
+		},
+		{
+			name:        "should retry truncated answers over TCP",
+			truncateUDP: true,
+			wantTCP:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newStubDNS(t, records...)
+			if tc.truncateUDP {
+				s.truncateUDP()
+			}
+
+			dr, err := NewDNSRemoter(context.Background(), DNSRemoterConfig{
+				Name:   "nodes.test",
+				Port:   7946,
+				Server: s.addr,
+			})
+			if err != nil {
+				t.Fatalf("error creating DNS remoter: %v", err)
+			}
+			t.Cleanup(func() { dr.Close() }) //nolint:errcheck
+
+			if members := dr.Members(); len(members) != len(records) {
+				t.Fatalf("expected %d members, but got %d", len(records), len(members))
+			}
+			if tcp := s.queriesTCP() > 0; tcp != tc.wantTCP {
+				t.Fatalf("expected TCP queries to be %v, but got %v", tc.wantTCP, tcp)
+			}
+		})
+	}
+}
+
+func TestDNSRemoterRefreshAfter(t *testing.T) {
+	t.Parallel()
+
+	dr := &DNSRemoter{config: DNSRemoterConfig{MinRefresh: time.Second, MaxRefresh: time.Minute}}
+
+	for ttl, want := range map[time.Duration]time.Duration{
+		0:                time.Second,
+		30 * time.Second: 30 * time.Second,
+		time.Hour:        time.Minute,
+	} {
+		if got := dr.refreshAfter(ttl); got != want {
+			t.Fatalf("expected refresh after ttl %v to be %v, but got %v", ttl, want, got)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const defFilePollInterval = 5 * time.Second
+
+const (
+	// FileFormatAuto detects the format from the file contents,
+	// being JSON if it starts with '[' and lines otherwise.
+	FileFormatAuto FileFormat = iota
+	// FileFormatJSON is a JSON array of FileMember.
+	FileFormatJSON
+	// FileFormatLines has one member per line, with its name followed
+	// by its optional host:port address and key=value attributes, being
+	// weight the member weight, up to MaxWeight, and any other key a
+	// tag, e.g.:
+	//
+	//	# comments and empty lines are ignored
+	//	srv0 10.0.0.1:7946 weight=2 role=cache
+	FileFormatLines
+)
+
+// FileFormat is the format of a membership file.
+type FileFormat int
+
+// ErrInvalidFile indicates that a membership file could not be parsed.
+var ErrInvalidFile = errors.New("invalid membership file")
+
+// FileMember is a member of a JSON membership file.
+type FileMember struct {
+	Name   string            `json:"name"`
+	Addr   string            `json:"addr,omitempty"`
+	Port   uint16            `json:"port,omitempty"`
+	Weight float64           `json:"weight,omitempty"`
+	Tags   map[string]string `json:"tags,omitempty"`
+}
+
+// FileRemoterConfig defines the file a FileRemoter reads
+// the membership from and how it watches for changes.
+type FileRemoterConfig struct {
+	Path   string
+	Format FileFormat
+	// PollInterval is the interval on which the file is read looking
+	// for changes. Defaults to 5s, and a negative value disables it.
+	PollInterval time.Duration
+	// ReloadOnSIGHUP makes the file to be read when the process
+	// receives a SIGHUP signal.
+	ReloadOnSIGHUP bool
+
+	// Logger is used by the FileRemoter. Defaults to slog.Default().
+	Logger *slog.Logger
+	// ErrorHandler, if set, is notified of the errors reading
+	// the file in background.
+	ErrorHandler func(error)
+}
+
+// FileRemoter is a Remoter which reads the membership from a static
+// file, as managed by configuration management tools, delivering the
+// differences between its consecutive contents as events. If reading
+// the file fails the last known membership is kept.
+type FileRemoter struct {
+	*memberSet
+
+	config FileRemoterConfig
+	logger *slog.Logger
+
+	rawMu sync.Mutex
+	raw   []byte
+	hup   chan os.Signal
+}
+
+// NewFileRemoter creates a new FileRemoter for the given config, and
+// reads the file for the first time, returning an error if it fails.
+// The initial members are not delivered as events, but returned by
+// Members. The FileRemoter must be closed once done.
+func NewFileRemoter(config FileRemoterConfig) (*FileRemoter, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("%w: empty file path", ErrInvalidConfig)
+	}
+	switch config.Format {
+	case FileFormatAuto, FileFormatJSON, FileFormatLines:
+	default:
+		return nil, fmt.Errorf("%w: unknown file format %d", ErrInvalidConfig, config.Format)
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	fr := &FileRemoter{
+		config: config,
+		logger: logger,
+	}
+
+	raw, err := fr.read()
+	if err != nil {
+		return nil, err
+	}
+	members, err := parseMembershipFile(raw, config.Format)
+	if err != nil {
+		return nil, err
+	}
+	fr.raw = raw
+	fr.memberSet = newMemberSet(members)
+
+	if config.ReloadOnSIGHUP {
+		fr.hup = make(chan os.Signal, 1)
+		signal.Notify(fr.hup, syscall.SIGHUP)
+	}
+
+	fr.wg.Add(1)
+	go fr.watch()
+
+	return fr, nil
+}
+
+// Reload reads the file and delivers the changes on its membership.
+func (fr *FileRemoter) Reload() error {
+	raw, err := fr.read()
+	if err != nil {
+		return err
+	}
+
+	fr.rawMu.Lock()
+	defer fr.rawMu.Unlock()
+
+	if bytes.Equal(raw, fr.raw) {
+		return nil
+	}
+
+	members, err := parseMembershipFile(raw, fr.config.Format)
+	if err != nil {
+		return err
+	}
+	fr.memberSet.update(members)
+	fr.raw = raw
+
+	return nil
+}
+
+func (fr *FileRemoter) read() ([]byte, error) {
+	raw, err := os.ReadFile(fr.config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading membership file: %w", err)
+	}
+
+	return raw, nil
+}
+
+// Close stops watching the file and closes the events channel.
+// Close is safe to be called multiple times.
+func (fr *FileRemoter) Close() error {
+	fr.memberSet.close()
+	return nil
+}
+
+// watch reads the file on every poll interval or
+// SIGHUP signal until the FileRemoter is closed.
+func (fr *FileRemoter) watch() {
+	defer fr.wg.Done()
+
+	var tick <-chan time.Time
+	interval := fr.config.PollInterval
+	if interval == 0 {
+		interval = defFilePollInterval
+	}
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	if fr.hup != nil {
+		defer signal.Stop(fr.hup)
+	}
+
+	for {
+		select {
+		case <-tick:
+		case <-fr.hup:
+			fr.logger.Info("reloading membership file", "path", fr.config.Path)
+		case <-fr.done:
+			return
+		}
+
+		if err := fr.Reload(); err != nil {
+			fr.reportErr(err)
+		}
+	}
+}
+
+func (fr *FileRemoter) reportErr(err error) {
+	fr.logger.Error("error reloading membership file", "path", fr.config.Path, "error", err)
+
+	if fr.config.ErrorHandler != nil {
+		fr.config.ErrorHandler(err)
+	}
+}
+
+// parseMembershipFile parses the given membership file contents.
+func parseMembershipFile(raw []byte, format FileFormat) (map[string]Member, error) {
+	if format == FileFormatAuto {
+		format = FileFormatLines
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+			format = FileFormatJSON
+		}
+	}
+
+	var (
+		fileMembers []FileMember
+		err         error
+	)
+	if format == FileFormatJSON {
+		err = json.Unmarshal(raw, &fileMembers)
+	} else {
+		fileMembers, err = parseLines(raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	members := make(map[string]Member, len(fileMembers))
+	for _, fm := range fileMembers {
+		m, err := fm.member()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+		if _, ok := members[m.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicated member %s", ErrInvalidFile, m.Name)
+		}
+		members[m.Name] = m
+	}
+
+	return members, nil
+}
+
+func parseLines(raw []byte) ([]FileMember, error) {
+	var members []FileMember
+
+	s := bufio.NewScanner(bytes.NewReader(raw))
+	for line := 1; s.Scan(); line++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		fm := FileMember{Name: fields[0]}
+		for _, f := range fields[1:] {
+			k, v, ok := strings.Cut(f, "=")
+			switch {
+			case !ok:
+				host, port, err := net.SplitHostPort(f)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", line, err)
+				}
+				p, err := strconv.ParseUint(port, 10, 16)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid port %q", line, port)
+				}
+				fm.Addr, fm.Port = host, uint16(p)
+			case k == "weight":
+				w, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid weight %q", line, v)
+				}
+				fm.Weight = w
+			default:
+				if fm.Tags == nil {
+					fm.Tags = make(map[string]string)
+				}
+				fm.Tags[k] = v
+			}
+		}
+		members = append(members, fm)
+	}
+
+	return members, s.Err()
+}
+
+func (fm FileMember) member() (Member, error) {
+	if fm.Name == "" {
+		return Member{}, errors.New("member without name")
+	}
+	if fm.Weight < 0 || fm.Weight > MaxWeight || math.IsNaN(fm.Weight) {
+		return Member{}, fmt.Errorf("member %s has invalid weight %v", fm.Name, fm.Weight)
+	}
+
+	m := Member{
+		Name:   fm.Name,
+		Port:   fm.Port,
+		Tags:   fm.Tags,
+		Weight: fm.Weight,
+	}
+	if fm.Addr != "" {
+		if m.Addr = net.ParseIP(fm.Addr); m.Addr == nil {
+			return Member{}, fmt.Errorf("member %s address %q is not an IP address", fm.Name, fm.Addr)
+		}
+	}
+
+	return m, nil
+}
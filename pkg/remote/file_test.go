@@ -0,0 +1,170 @@
+package remote
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseMembershipFile(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		raw         string
+		format      FileFormat
+		wantMembers map[string]Member
+		wantErr     error
+	}{
+		{
+			name: "should parse lines",
+			raw: `# members
+srv0 10.0.0.1:7946 weight=2 role=cache
+
+srv1
+`,
+			wantMembers: map[string]Member{
+				"srv0": {Name: "srv0", Addr: net.ParseIP("10.0.0.1"), Port: 7946, Weight: 2, Tags: map[string]string{"role": "cache"}},
+				"srv1": {Name: "srv1"},
+			},
+		},
+		{
+			name: "should parse JSON",
+			raw:  `[{"name": "srv0", "addr": "10.0.0.1", "port": 7946, "weight": 2, "tags": {"role": "cache"}}]`,
+			wantMembers: map[string]Member{
+				"srv0": {Name: "srv0", Addr: net.ParseIP("10.0.0.1"), Port: 7946, Weight: 2, Tags: map[string]string{"role": "cache"}},
+			},
+		},
+		{
+			name:        "should parse empty file",
+			raw:         "",
+			wantMembers: map[string]Member{},
+		},
+		{
+			name:    "should return error on invalid JSON",
+			raw:     `[{"name": "srv0"`,
+			wantErr: ErrInvalidFile,
+		},
+		{
+			name:    "should return error on JSON member without name",
+			raw:     `[{"addr": "10.0.0.1"}]`,
+			format:  FileFormatJSON,
+			wantErr: ErrInvalidFile,
+		},
+		{
+			name:    "should return error on invalid address",
+			raw:     "srv0 10.0.0.1",
+			wantErr: ErrInvalidFile,
+		},
+		{
+			name:    "should return error on negative weight",
+			raw:     "srv0 weight=-1",
+			wantErr: ErrInvalidFile,
+		},
+		{
+			name:    "should return error on weight above maximum",
+			raw:     "srv0 weight=1e9",
+			wantErr: ErrInvalidFile,
+		},
+		{
+			name:    "should return error on duplicated member",
+			raw:     "srv0\nsrv0",
+			format:  FileFormatLines,
+			wantErr: ErrInvalidFile,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			members, err := parseMembershipFile([]byte(tc.raw), tc.format)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("unexpected error. want: %v but got: %v", tc.wantErr, err)
+			}
+			if err == nil && !reflect.DeepEqual(members, tc.wantMembers) {
+				t.Fatalf("expected members to be %v, but got %v", tc.wantMembers, members)
+			}
+		})
+	}
+}
+
+// writeFile writes the given contents into the given path.
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+}
+
+// waitEvents waits until the given events are received from ch.
+func waitEvents(t *testing.T, ch <-chan Event, want ...Event) {
+	t.Helper()
+
+	for _, w := range want {
+		select {
+		case e := <-ch:
+			if e.Typ != w.Typ || e.Name != w.Name {
+				t.Fatalf("expected event %v for %s, but got %v for %s", w.Typ, w.Name, e.Typ, e.Name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for event %v for %s", w.Typ, w.Name)
+		}
+	}
+}
+
+func TestFileRemoter(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "members")
+	writeFile(t, path, "srv0\nsrv1 weight=1\n")
+
+	errs := make(chan error, 10)
+	fr, err := NewFileRemoter(FileRemoterConfig{
+		Path:         path,
+		PollInterval: 10 * time.Millisecond,
+		ErrorHandler: func(err error) { errs <- err },
+	})
+	if err != nil {
+		t.Fatalf("error creating file remoter: %v", err)
+	}
+	t.Cleanup(func() { fr.Close() }) //nolint:errcheck
+
+	if got := len(fr.Members()); got != 2 {
+		t.Fatalf("expected members len to be 2, but got %d", got)
+	}
+
+	writeFile(t, path, "srv1 weight=2\nsrv2\n")
+	waitEvents(t, fr.EventsCh(),
+		Event{Typ: EventLeave, Name: "srv0"},
+		Event{Typ: EventUpdate, Name: "srv1"},
+		Event{Typ: EventJoin, Name: "srv2"},
+	)
+
+	// Invalid contents keep the last known membership
+	writeFile(t, path, "srv1 weight=x\n")
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrInvalidFile) {
+			t.Fatalf("unexpected error. want: %v but got: %v", ErrInvalidFile, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for error")
+	}
+	if got := fr.Members(); len(got) != 2 || got[0].Weight != 2 {
+		t.Fatalf("expected last known members to be kept, but got %v", got)
+	}
+
+	if err := fr.Close(); err != nil {
+		t.Fatalf("error closing file remoter: %v", err)
+	}
+	if _, ok := <-fr.EventsCh(); ok {
+		t.Fatal("expected events channel to be closed")
+	}
+}
@@ -0,0 +1,33 @@
+//go:build unix
+
+package remote
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestFileRemoterSIGHUP(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "members")
+	writeFile(t, path, "srv0\n")
+
+	fr, err := NewFileRemoter(FileRemoterConfig{
+		Path:           path,
+		PollInterval:   -1,
+		ReloadOnSIGHUP: true,
+	})
+	if err != nil {
+		t.Fatalf("error creating file remoter: %v", err)
+	}
+	t.Cleanup(func() { fr.Close() }) //nolint:errcheck
+
+	writeFile(t, path, "srv0\nsrv1\n")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("error sending SIGHUP: %v", err)
+	}
+	waitEvents(t, fr.EventsCh(), Event{Typ: EventJoin, Name: "srv1"})
+}
@@ -2,127 +2,500 @@ package remote
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/memberlist"
 )
 
-// GossipEvents implements the memberlist.EventDelegate
-// interface in order to receive notifications about members
-// joining and leaving the cluster.
+// GossipEvents implements the memberlist.EventDelegate and
+// memberlist.ConflictDelegate interfaces in order to receive
+// notifications about members joining and leaving the cluster,
+// and about nodes using the name of an existing member.
+//
+// It also keeps a copy of the live nodes, as the ones returned by
+// memberlist are modified in place while holding its internal lock,
+// so they cannot be safely read outside of the notifications.
 type GossipEvents struct {
-	eventsCh chan Event
+	queue *eventQueue
+
+	mu    sync.Mutex
+	nodes map[string]memberlist.Node
+	subs  map[*Subscription]struct{}
 }
 
 // NotifyJoin is invoked when a node is detected to have joined.
 // The Node argument must not be modified.
 func (ge *GossipEvents) NotifyJoin(node *memberlist.Node) {
-	ge.eventsCh <- Event{
-		Typ:  EventJoin,
-		Name: node.Name,
-		Addr: node.Addr,
-		Port: node.Port,
-	}
+	ge.record(node, true)
+	ge.push(nodeToEvent(EventJoin, node))
 }
 
 // NotifyLeave is invoked when a node is detected to have left.
 // The Node argument must not be modified.
 func (ge *GossipEvents) NotifyLeave(node *memberlist.Node) {
-	ge.eventsCh <- Event{
-		Typ:  EventLeave,
-		Name: node.Name,
-		Addr: node.Addr,
-		Port: node.Port,
-	}
+	ge.record(node, false)
+	ge.push(nodeToEvent(EventLeave, node))
 }
 
 // NotifyUpdate is invoked when a node is detected to have
 // updated, usually involving the meta data. The Node argument
 // must not be modified.
 func (ge *GossipEvents) NotifyUpdate(node *memberlist.Node) {
-	// Noop
+	ge.record(node, true)
+	ge.push(nodeToEvent(EventUpdate, node))
 }
 
-const (
-	GossiperNetworkLocal GossiperNetwork = iota
-	GossiperNetworkLAN
-	GossiperNetworkWAN
-)
+// NotifyConflict is invoked when a name conflict is detected,
+// being other a node using the same name as the existing member.
+func (ge *GossipEvents) NotifyConflict(existing, other *memberlist.Node) {
+	ge.push(nodeToEvent(EventConflict, other))
+}
+
+// push queues the given event for EventsCh
+// and for every subscription.
+func (ge *GossipEvents) push(e Event) {
+	ge.queue.push(e)
+
+	ge.mu.Lock()
+	defer ge.mu.Unlock()
+
+	for s := range ge.subs {
+		s.queue.push(e)
+	}
+}
+
+func (ge *GossipEvents) subscribe(s *Subscription) {
+	ge.mu.Lock()
+	defer ge.mu.Unlock()
+
+	ge.subs[s] = struct{}{}
+}
+
+func (ge *GossipEvents) unsubscribe(s *Subscription) {
+	ge.mu.Lock()
+	defer ge.mu.Unlock()
+
+	delete(ge.subs, s)
+}
 
-type GossiperNetwork int
+// record records a copy of the given node if it is live,
+// otherwise forgets it. Memberlist replaces the address and
+// metadata of the nodes instead of modifying them, so copying
+// the node is enough.
+func (ge *GossipEvents) record(node *memberlist.Node, live bool) {
+	ge.mu.Lock()
+	defer ge.mu.Unlock()
 
-type GossiperConfig struct {
-	NodeName string
-	NodeList []string
-	Network  GossiperNetwork
-	Port     int
+	if live {
+		ge.nodes[node.Name] = *node
+	} else {
+		delete(ge.nodes, node.Name)
+	}
+}
+
+// liveNodes returns a copy of the live nodes.
+func (ge *GossipEvents) liveNodes() []*memberlist.Node {
+	ge.mu.Lock()
+	defer ge.mu.Unlock()
+
+	nodes := make([]*memberlist.Node, 0, len(ge.nodes))
+	for _, n := range ge.nodes {
+		n := n
+		nodes = append(nodes, &n)
+	}
+
+	return nodes
+}
+
+// liveNode returns a copy of the live node with the given name.
+func (ge *GossipEvents) liveNode(name string) (*memberlist.Node, bool) {
+	ge.mu.Lock()
+	defer ge.mu.Unlock()
+
+	n, ok := ge.nodes[name]
+	return &n, ok
 }
 
+const defLeaveTimeout = 5 * time.Second
+
+var (
+	// ErrGossiperNotStarted indicates that the Gossiper has not been started yet.
+	ErrGossiperNotStarted = errors.New("gossiper has not been started")
+	// ErrGossiperStarted indicates that the Gossiper has already been started.
+	ErrGossiperStarted = errors.New("gossiper has already been started")
+	// ErrGossiperShutdown indicates that the Gossiper has been shut down.
+	ErrGossiperShutdown = errors.New("gossiper has been shut down")
+)
+
+// Gossiper is a Remoter which uses a gossip protocol in order to
+// manage the cluster membership.
+//
+// The lifecycle of a Gossiper is explicit: it is created with
+// NewGossiper, started with Start, which binds its listeners, and
+// connected to an existing cluster with Join. Once done, the node
+// should Leave the cluster and Shutdown, or just Close it. After
+// shutdown the events channel is closed.
+//
+// EventsCh must have a single consumer. Several consumers, such as
+// rings for different groups of members, must Subscribe instead.
 type Gossiper struct {
-	ml     *memberlist.Memberlist
-	events *GossipEvents
-}
-
-// NewGossiper creates a new Gossiper which joins the cluster defined
-// by the node list. Given wait group should not be incremented previously
-// to this call, as it will be incremented in this constructor once the
-// Gossiper is correctly initialized. This wait group can then be used
-// to wait for the Gossiper to gracefully leave the cluster once the given
-// context is canceled. Otherwise it will take more time for the cluster to
-// realize that this node is down.
-func NewGossiper(ctx context.Context, wg *sync.WaitGroup, config GossiperConfig) (*Gossiper, error) {
+	config GossiperConfig
+	logger *slog.Logger
+	name   string
+	// maxBestEffort is the maximum size of a best effort
+	// user message, set on start.
+	maxBestEffort int
+	allowedCIDRs  []*net.IPNet
+
+	mu       sync.Mutex
+	ml       *memberlist.Memberlist
+	keyring  *memberlist.Keyring
+	seeds    []string
+	shutdown bool
+
+	metaMu      sync.Mutex
+	tags        map[string]string
+	incarnation uint64
+
+	degraded   atomic.Bool
+	rejoinOnce sync.Once
+
+	broadcasts atomic.Pointer[memberlist.TransmitLimitedQueue]
+	checksums  *checksums
+	ringConfig *ringConfig
+	msgHandler atomic.Pointer[MessageHandler]
+	rtts       *rtts
+
+	events   *GossipEvents
+	eventsCh chan Event
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopped  chan struct{}
+}
+
+// NewGossiper creates a new Gossiper for the given config.
+// If the config is not valid returns an error wrapping ErrInvalidConfig.
+// No network resources are acquired until the Gossiper is started.
+func NewGossiper(config GossiperConfig) (*Gossiper, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	allowedCIDRs, err := parseCIDRs(config.AllowedCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	// Events are queued instead of sent directly to the
+	// events channel in order to prevent blocking memberlist
+	// while the consumer of Gossiper is not reading from it
 	events := &GossipEvents{
-		// Set initial buffer to prevent blocking
-		// the main thread meanwhile the consumer
-		// of Gossiper starts reading the channel
-		eventsCh: make(chan Event, 10),
+		queue: newEventQueue(config.EventQueueSize, config.EventQueueOverflow),
+		nodes: make(map[string]memberlist.Node),
+		subs:  make(map[*Subscription]struct{}),
 	}
 
-	// TODO: Move memberlist init away from constructor?
-	var mlConfig *memberlist.Config
-	switch config.Network {
-	case GossiperNetworkWAN:
-		mlConfig = memberlist.DefaultWANConfig()
-	case GossiperNetworkLocal:
-		mlConfig = memberlist.DefaultLocalConfig()
-	default:
-		mlConfig = memberlist.DefaultLANConfig()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Gossiper{
+		config:       config,
+		logger:       logger,
+		allowedCIDRs: allowedCIDRs,
+		tags:         config.Tags,
+		checksums:    &checksums{peers: make(map[string]*peerChecksum)},
+		ringConfig:   newRingConfig(),
+		rtts:         &rtts{byName: make(map[string]time.Duration)},
+		events:       events,
+		eventsCh:     make(chan Event),
+		ctx:          ctx,
+		cancel:       cancel,
+		stopped:      make(chan struct{}),
+	}, nil
+}
+
+// Start creates the underlying memberlist, binding its listeners,
+// and starts delivering events. At this point the Gossiper is a
+// cluster of one node, which other nodes can join to.
+func (g *Gossiper) Start() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.shutdown {
+		return ErrGossiperShutdown
 	}
+	if g.ml != nil {
+		return ErrGossiperStarted
+	}
+
+	mlConfig := g.config.memberlistConfig()
+	g.name = mlConfig.Name
+	g.maxBestEffort = maxBestEffortSize(mlConfig)
+	mlConfig.Logger = newMemberlistLogger(g.logger)
 
-	mlConfig.Name = config.NodeName
-	mlConfig.BindPort = config.Port
-	mlConfig.AdvertisePort = config.Port
-	mlConfig.Events = events
+	g.metaMu.Lock()
+	g.incarnation = uint64(time.Now().UnixNano())
+	g.metaMu.Unlock()
+	mlConfig.Events = g.events
+	mlConfig.Conflict = g.events
+	mlConfig.Delegate = &gossipDelegate{g: g}
+	mlConfig.Alive = &gossipAdmission{g: g}
+	mlConfig.Merge = &gossipAdmission{g: g}
+	mlConfig.Ping = &gossipPing{g: g}
+
+	if keys := g.config.SecretKeys; len(keys) > 0 {
+		kr, err := memberlist.NewKeyring(keys, keys[0])
+		if err != nil {
+			return fmt.Errorf("error creating keyring: %w", err)
+		}
+		g.keyring = kr
+		mlConfig.Keyring = kr
+		// Never accept nor send plaintext messages
+		mlConfig.GossipVerifyIncoming = true
+		mlConfig.GossipVerifyOutgoing = true
+	}
 
 	ml, err := memberlist.Create(mlConfig)
 	if err != nil {
-		return nil, fmt.Errorf("error creating memberlist: %w", err)
+		return fmt.Errorf("error creating memberlist: %w", err)
 	}
+	g.ml = ml
+
+	g.broadcasts.Store(&memberlist.TransmitLimitedQueue{
+		NumNodes:       ml.NumMembers,
+		RetransmitMult: mlConfig.RetransmitMult,
+	})
+	g.checksums.mu.Lock()
+	g.broadcastChecksumLocked()
+	g.checksums.mu.Unlock()
+
+	go g.checkDivergence()
+	go g.pruneRTTs()
+	go g.pruneRingConfig()
+	go func() {
+		g.events.queue.run(g.eventsCh, g.ctx.Done())
+		close(g.eventsCh)
+		close(g.stopped)
+	}()
+
+	return nil
+}
 
-	_, err = ml.Join(config.NodeList)
+// Join joins the cluster which the given seed nodes are part of,
+// following the join strategy defined in the Gossiper config.
+// Returns the number of seeds successfully contacted, or an error
+// if none of them could be reached before the join deadline or the
+// context is done. If degraded mode is allowed, no error is returned
+// and the Gossiper keeps trying to join in background instead.
+func (g *Gossiper) Join(ctx context.Context, seeds []string) (int, error) {
+	ml, err := g.memberlist()
 	if err != nil {
-		return nil, fmt.Errorf("error joining the cluster: %w", err)
+		return 0, err
 	}
 
-	wg.Add(1)
+	g.mu.Lock()
+	g.seeds = seeds
+	g.mu.Unlock()
 
-	go func() {
-		<-ctx.Done()
-		if err := ml.Leave(5 * time.Second); err != nil {
-			log.Printf("error leaving memberlist cluster: %v", err)
+	if !g.hasSeeds() {
+		return 0, nil
+	}
+
+	n, err := g.joinWithRetry(ctx, ml)
+	if err != nil && !g.config.Join.AllowDegraded {
+		return 0, err
+	}
+
+	interval := g.config.Join.RejoinInterval
+	if err != nil {
+		g.logger.Warn("starting in degraded state", "error", err)
+		g.degraded.Store(true)
+		if interval <= 0 {
+			interval = defRejoinInterval
 		}
-		wg.Done()
-	}()
+	}
 
-	return &Gossiper{
-		ml:     ml,
-		events: events,
-	}, nil
+	if interval > 0 {
+		g.rejoinOnce.Do(func() {
+			go g.rejoin(ml, interval)
+		})
+	}
+
+	return n, nil
+}
+
+// Leave broadcasts a leave message to the cluster and waits for it
+// to be sent, up to the given timeout. Leaving without shutting down
+// is possible, although the node will not be able to join again.
+func (g *Gossiper) Leave(timeout time.Duration) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.shutdown {
+		return ErrGossiperShutdown
+	}
+	if g.ml == nil {
+		return ErrGossiperNotStarted
+	}
+
+	if err := g.ml.Leave(timeout); err != nil {
+		return fmt.Errorf("error leaving the cluster: %w", err)
+	}
+
+	return nil
+}
+
+// Shutdown stops all the Gossiper network activity and closes the
+// events channel. It does not notify the cluster, which has to
+// detect the node as failed, so Leave should be called first.
+// Shutdown is safe to be called multiple times.
+func (g *Gossiper) Shutdown() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.shutdown {
+		return nil
+	}
+	g.shutdown = true
+
+	g.events.queue.close()
+	g.cancel()
+
+	if g.ml == nil {
+		close(g.eventsCh)
+		return nil
+	}
+
+	<-g.stopped
+
+	if err := g.ml.Shutdown(); err != nil {
+		return fmt.Errorf("error shutting down memberlist: %w", err)
+	}
+
+	return nil
+}
+
+// Close gracefully leaves the cluster and shuts down the Gossiper.
+func (g *Gossiper) Close() error {
+	var leaveErr error
+	err := g.Leave(defLeaveTimeout)
+	if err != nil && !errors.Is(err, ErrGossiperNotStarted) && !errors.Is(err, ErrGossiperShutdown) {
+		leaveErr = err
+	}
+
+	if err := g.Shutdown(); err != nil {
+		return err
+	}
+
+	return leaveErr
 }
 
 func (g *Gossiper) EventsCh() <-chan Event {
-	return g.events.eventsCh
+	return g.eventsCh
+}
+
+// Members returns the list of live members known by the Gossiper,
+// including itself. Returns nil if the Gossiper is not running.
+func (g *Gossiper) Members() []Member {
+	if _, err := g.memberlist(); err != nil {
+		return nil
+	}
+
+	nodes := g.events.liveNodes()
+
+	members := make([]Member, len(nodes))
+	for i, n := range nodes {
+		members[i] = nodeToMember(n)
+	}
+
+	return members
+}
+
+// LocalMember returns the member representing the local node.
+// Returns false if the Gossiper is not running.
+func (g *Gossiper) LocalMember() (Member, bool) {
+	if _, err := g.memberlist(); err != nil {
+		return Member{}, false
+	}
+
+	n, ok := g.events.liveNode(g.name)
+	if !ok {
+		return Member{}, false
+	}
+
+	return nodeToMember(n), true
+}
+
+// SetTags replaces the tags announced by the local node and
+// propagates them to the cluster, waiting up to the given timeout.
+func (g *Gossiper) SetTags(tags map[string]string, timeout time.Duration) error {
+	ml, err := g.memberlist()
+	if err != nil {
+		return err
+	}
+
+	g.metaMu.Lock()
+	meta := g.localMetaLocked()
+	meta.Tags = tags
+	if err := validateMetaSize(meta); err != nil {
+		g.metaMu.Unlock()
+		return err
+	}
+	g.tags = tags
+	g.metaMu.Unlock()
+
+	if err := ml.UpdateNode(timeout); err != nil {
+		return fmt.Errorf("error updating node tags: %w", err)
+	}
+
+	return nil
+}
+
+// QueueStats returns the current state of the queue of events
+// pending to be read from EventsCh.
+func (g *Gossiper) QueueStats() QueueStats {
+	return g.events.queue.stats()
+}
+
+// memberlist returns the underlying memberlist if the Gossiper
+// is running, otherwise returns an error.
+func (g *Gossiper) memberlist() (*memberlist.Memberlist, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.shutdown {
+		return nil, ErrGossiperShutdown
+	}
+	if g.ml == nil {
+		return nil, ErrGossiperNotStarted
+	}
+
+	return g.ml, nil
+}
+
+func nodeToMember(n *memberlist.Node) Member {
+	// Nodes with invalid metadata are never admitted,
+	// so the error can be safely ignored
+	meta, _ := decodeMeta(n.Meta)
+
+	return Member{
+		Name:        n.Name,
+		Addr:        n.Addr,
+		Port:        n.Port,
+		Tags:        meta.Tags,
+		Incarnation: meta.Incarnation,
+	}
+}
+
+func nodeToEvent(typ EventType, n *memberlist.Node) Event {
+	return NewMemberEvent(typ, nodeToMember(n))
 }
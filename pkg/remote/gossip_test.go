@@ -0,0 +1,289 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGossiperLifecycle(t *testing.T) {
+	t.Parallel()
+
+	g, err := NewGossiper(GossiperConfig{NodeName: "node0", Network: GossiperNetworkLocal})
+	if err != nil {
+		t.Fatalf("error creating gossiper: %v", err)
+	}
+
+	if _, err := g.Join(context.Background(), []string{"127.0.0.1:1"}); !errors.Is(err, ErrGossiperNotStarted) {
+		t.Fatalf("unexpected error. want: %v but got: %v", ErrGossiperNotStarted, err)
+	}
+	if err := g.Start(); err != nil {
+		t.Fatalf("error starting gossiper: %v", err)
+	}
+	if err := g.Start(); !errors.Is(err, ErrGossiperStarted) {
+		t.Fatalf("unexpected error. want: %v but got: %v", ErrGossiperStarted, err)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatalf("error closing gossiper: %v", err)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatalf("error closing gossiper twice: %v", err)
+	}
+	if _, ok := <-g.EventsCh(); ok {
+		t.Fatal("expected events channel to be closed")
+	}
+}
+
+func TestGossiperJoin(t *testing.T) {
+	t.Parallel()
+
+	g0 := newTestGossiper(t, "node0")
+	g1 := newTestGossiper(t, "node1")
+
+	n, err := g1.Join(context.Background(), []string{gossiperAddr(t, g0)})
+	if err != nil {
+		t.Fatalf("error joining cluster: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected to contact 1 node, but got %d", n)
+	}
+
+	waitEvent(t, g0, EventJoin, "node1")
+
+	if err := g1.Close(); err != nil {
+		t.Fatalf("error closing gossiper: %v", err)
+	}
+
+	waitEvent(t, g0, EventLeave, "node1")
+}
+
+func TestGossiperTags(t *testing.T) {
+	t.Parallel()
+
+	g0 := newTestGossiper(t, "node0")
+	g1 := newTestGossiper(t, "node1", func(c *GossiperConfig) {
+		c.Tags = map[string]string{"role": "proxy"}
+	})
+
+	if _, err := g1.Join(context.Background(), []string{gossiperAddr(t, g0)}); err != nil {
+		t.Fatalf("error joining cluster: %v", err)
+	}
+	if e := waitEvent(t, g0, EventJoin, "node1"); e.Tags["role"] != "proxy" {
+		t.Fatalf("expected node1 role to be proxy, but got %q", e.Tags["role"])
+	}
+
+	if err := g1.SetTags(map[string]string{"role": "storage"}, time.Second); err != nil {
+		t.Fatalf("error setting tags: %v", err)
+	}
+	waitEvent(t, g0, EventUpdate, "node1")
+
+	for _, m := range g0.Members() {
+		if m.Name == "node1" && m.Tags["role"] != "storage" {
+			t.Fatalf("expected node1 role to be storage, but got %q", m.Tags["role"])
+		}
+	}
+}
+
+func TestGossiperConflict(t *testing.T) {
+	t.Parallel()
+
+	g0 := newTestGossiper(t, "node0")
+	g1 := newTestGossiper(t, "dup")
+	g2 := newTestGossiper(t, "dup")
+
+	if _, err := g1.Join(context.Background(), []string{gossiperAddr(t, g0)}); err != nil {
+		t.Fatalf("error joining cluster: %v", err)
+	}
+	waitEvent(t, g0, EventJoin, "dup")
+
+	g2.Join(context.Background(), []string{gossiperAddr(t, g0)}) //nolint:errcheck
+	waitEvent(t, g0, EventConflict, "dup")
+}
+
+func TestGossiperJoinRetry(t *testing.T) {
+	t.Parallel()
+
+	unreachable := []string{"127.0.0.1:1"}
+
+	g := newTestGossiper(t, "node0", func(c *GossiperConfig) {
+		c.Join = JoinConfig{
+			Deadline:       200 * time.Millisecond,
+			InitialBackoff: 10 * time.Millisecond,
+		}
+	})
+
+	if _, err := g.Join(context.Background(), unreachable); err == nil {
+		t.Fatal("expected join to fail")
+	}
+}
+
+func TestGossiperJoinDegraded(t *testing.T) {
+	t.Parallel()
+
+	g0 := newTestGossiper(t, "node0")
+	addr := gossiperAddr(t, g0)
+
+	var reachable atomic.Bool
+	g1 := newTestGossiper(t, "node1", func(c *GossiperConfig) {
+		c.Join = JoinConfig{
+			Deadline:       100 * time.Millisecond,
+			InitialBackoff: 10 * time.Millisecond,
+			AllowDegraded:  true,
+			RejoinInterval: 10 * time.Millisecond,
+			Resolver: func(ctx context.Context) ([]string, error) {
+				if !reachable.Load() {
+					return []string{"127.0.0.1:1"}, nil
+				}
+				return []string{addr}, nil
+			},
+		}
+	})
+
+	if _, err := g1.Join(context.Background(), nil); err != nil {
+		t.Fatalf("error joining cluster: %v", err)
+	}
+	if !g1.Degraded() {
+		t.Fatal("expected gossiper to be degraded")
+	}
+
+	reachable.Store(true)
+	waitEvent(t, g0, EventJoin, "node1")
+
+	timeout := time.After(5 * time.Second)
+	for g1.Degraded() {
+		select {
+		case <-timeout:
+			t.Fatal("timeout waiting for gossiper to recover")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// newTestGossiper creates and starts a new local Gossiper
+// which is closed on test cleanup.
+func newTestGossiper(t *testing.T, name string, opts ...func(*GossiperConfig)) *Gossiper {
+	t.Helper()
+
+	config := GossiperConfig{
+		NodeName: name,
+		Network:  GossiperNetworkLocal,
+	}
+	for _, o := range opts {
+		o(&config)
+	}
+
+	g, err := NewGossiper(config)
+	if err != nil {
+		t.Fatalf("error creating gossiper: %v", err)
+	}
+	if err := g.Start(); err != nil {
+		t.Fatalf("error starting gossiper: %v", err)
+	}
+	t.Cleanup(func() {
+		g.Close() //nolint:errcheck
+	})
+
+	return g
+}
+
+// gossiperAddr returns the address on which the given Gossiper
+// can be joined to.
+func gossiperAddr(t *testing.T, g *Gossiper) string {
+	t.Helper()
+
+	ml, err := g.memberlist()
+	if err != nil {
+		t.Fatalf("error retrieving memberlist: %v", err)
+	}
+	n := ml.LocalNode()
+
+	return net.JoinHostPort(n.Addr.String(), strconv.Itoa(int(n.Port)))
+}
+
+// waitEvent waits until an event of the given type for the given
+// node is received, ignoring any other event, and returns it.
+func waitEvent(t *testing.T, g *Gossiper, typ EventType, name string) Event {
+	t.Helper()
+
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case e, ok := <-g.EventsCh():
+			if !ok {
+				t.Fatal("events channel closed")
+			}
+			if e.Typ == typ && e.Name == name {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for event %v for node %s", typ, name)
+		}
+	}
+}
+
+func TestGossiperEpoch(t *testing.T) {
+	t.Parallel()
+
+	divergence := func(c *GossiperConfig) {
+		c.Divergence = DivergenceConfig{Interval: 20 * time.Millisecond}
+	}
+	g0 := newTestGossiper(t, "node0", divergence)
+	g1 := newTestGossiper(t, "node1", divergence)
+
+	if _, err := g1.Join(context.Background(), []string{gossiperAddr(t, g0)}); err != nil {
+		t.Fatalf("error joining cluster: %v", err)
+	}
+
+	// The epoch is gossiped along with the checksum
+	g0.PublishEpoch(5)
+	g0.PublishChecksum(1)
+	waitEvent(t, g1, EventEpoch, "")
+	if epoch := g1.Epoch(); epoch != 5 {
+		t.Fatalf("expected epoch to be %d, but got %d", 5, epoch)
+	}
+
+	// Lower epochs never decrease the greatest one
+	g1.PublishEpoch(3)
+	if epoch := g1.Epoch(); epoch != 5 {
+		t.Fatalf("expected epoch to be %d, but got %d", 5, epoch)
+	}
+}
+
+func TestGossiperDivergence(t *testing.T) {
+	t.Parallel()
+
+	divergence := func(c *GossiperConfig) {
+		c.Divergence = DivergenceConfig{
+			Interval:  20 * time.Millisecond,
+			Threshold: 100 * time.Millisecond,
+		}
+	}
+	g0 := newTestGossiper(t, "node0", divergence)
+	g1 := newTestGossiper(t, "node1", divergence)
+	g2 := newTestGossiper(t, "node2", divergence)
+
+	for _, g := range []*Gossiper{g1, g2} {
+		if _, err := g.Join(context.Background(), []string{gossiperAddr(t, g0)}); err != nil {
+			t.Fatalf("error joining cluster: %v", err)
+		}
+	}
+
+	g0.PublishChecksum(1)
+	g1.PublishChecksum(1)
+	g2.PublishChecksum(2)
+
+	waitEvent(t, g0, EventDivergence, "node2")
+
+	// Once node2 matches again, the divergence of node1 is
+	// reported after it changes its checksum
+	g2.PublishChecksum(1)
+	g1.PublishChecksum(3)
+	e := waitEvent(t, g0, EventDivergence, "node1")
+	if e.Addr == nil {
+		t.Fatal("expected divergence event to carry the node address")
+	}
+}
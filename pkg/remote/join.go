@@ -0,0 +1,184 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/memberlist"
+)
+
+const (
+	defJoinInitialBackoff = 500 * time.Millisecond
+	defJoinMaxBackoff     = 30 * time.Second
+	defRejoinInterval     = 30 * time.Second
+	defMinMembers         = 2
+)
+
+// JoinConfig defines how a Gossiper joins a cluster and how
+// it keeps itself joined to it.
+type JoinConfig struct {
+	// Deadline is the maximum time during which the initial join is
+	// retried. Zero means that the join is attempted only once.
+	Deadline time.Duration
+	// InitialBackoff is the time to wait before the first retry,
+	// which is doubled on each attempt up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// AllowDegraded makes Join succeed even if no seed could be
+	// reached before the deadline. The Gossiper then starts in a
+	// degraded state and keeps trying to join in background.
+	AllowDegraded bool
+	// RejoinInterval is the interval on which the number of members
+	// is checked against MinMembers, re-joining the seeds if it is
+	// lower. Zero disables the periodic re-join, unless the Gossiper
+	// is degraded.
+	RejoinInterval time.Duration
+	// MinMembers is the expected minimum number of live members,
+	// including the local node. Defaults to 2.
+	MinMembers int
+	// Resolver, if set, is used to resolve the seeds before every
+	// join attempt instead of using the seeds given to Join.
+	Resolver func(ctx context.Context) ([]string, error)
+}
+
+// Degraded reports whether the Gossiper could not join its seeds,
+// or its number of members dropped below the expected minimum.
+func (g *Gossiper) Degraded() bool {
+	return g.degraded.Load()
+}
+
+// joinWithRetry joins the cluster retrying with an exponential
+// backoff until it succeeds, the join deadline is exceeded or the
+// context is done.
+func (g *Gossiper) joinWithRetry(ctx context.Context, ml *memberlist.Memberlist) (int, error) {
+	jc := g.config.Join
+
+	var deadline <-chan time.Time
+	if jc.Deadline > 0 {
+		timer := time.NewTimer(jc.Deadline)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	backoff := jc.InitialBackoff
+	if backoff <= 0 {
+		backoff = defJoinInitialBackoff
+	}
+	maxBackoff := jc.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defJoinMaxBackoff
+	}
+
+	for {
+		n, err := g.join(ctx, ml)
+		if err == nil {
+			return n, nil
+		}
+		if deadline == nil {
+			return 0, err
+		}
+
+		g.logger.Warn("error joining the cluster, retrying", "backoff", backoff, "error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-deadline:
+			return 0, fmt.Errorf("join deadline exceeded: %w", err)
+		case <-ctx.Done():
+			return 0, fmt.Errorf("error joining the cluster: %w", ctx.Err())
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// join makes a single attempt to join the cluster seeds.
+func (g *Gossiper) join(ctx context.Context, ml *memberlist.Memberlist) (int, error) {
+	seeds, err := g.resolveSeeds(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	type joinResult struct {
+		n   int
+		err error
+	}
+	resCh := make(chan joinResult, 1)
+
+	go func() {
+		n, err := ml.Join(seeds)
+		resCh <- joinResult{n, err}
+	}()
+
+	select {
+	case res := <-resCh:
+		if res.err != nil {
+			return res.n, fmt.Errorf("error joining the cluster: %w", res.err)
+		}
+		return res.n, nil
+	case <-ctx.Done():
+		return 0, fmt.Errorf("error joining the cluster: %w", ctx.Err())
+	}
+}
+
+func (g *Gossiper) resolveSeeds(ctx context.Context) ([]string, error) {
+	if g.config.Join.Resolver == nil {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.seeds, nil
+	}
+
+	seeds, err := g.config.Join.Resolver(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving seeds: %w", err)
+	}
+
+	return seeds, nil
+}
+
+// hasSeeds reports whether there is any source of seeds to join to.
+func (g *Gossiper) hasSeeds() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return len(g.seeds) > 0 || g.config.Join.Resolver != nil
+}
+
+// rejoin periodically checks the number of live members and
+// re-joins the seeds if it is lower than the expected minimum,
+// until the Gossiper is shut down.
+func (g *Gossiper) rejoin(ml *memberlist.Memberlist, interval time.Duration) {
+	minMembers := g.config.Join.MinMembers
+	if minMembers <= 0 {
+		minMembers = defMinMembers
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-g.ctx.Done():
+			return
+		}
+
+		if ml.NumMembers() >= minMembers {
+			g.degraded.Store(false)
+			continue
+		}
+
+		g.degraded.Store(true)
+		if _, err := g.join(g.ctx, ml); err != nil {
+			g.reportErr("error re-joining the cluster", err)
+			continue
+		}
+		if ml.NumMembers() >= minMembers {
+			g.degraded.Store(false)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/memberlist"
+)
+
+// ErrEncryptionDisabled indicates that the Gossiper was not
+// configured with any secret key.
+var ErrEncryptionDisabled = errors.New("gossip encryption is disabled")
+
+const (
+	keyringOpInstall keyringOp = iota
+	keyringOpUse
+	keyringOpRemove
+)
+
+type keyringOp int
+
+type keyringMsg struct {
+	Op  keyringOp `json:"op"`
+	Key []byte    `json:"key"`
+}
+
+// KeyringError is returned when a keyring operation was applied
+// locally but could not be sent to some of the cluster members.
+type KeyringError struct {
+	// Failed maps the name of each unreachable member to the
+	// error returned when sending the operation to it.
+	Failed map[string]error
+}
+
+func (e *KeyringError) Error() string {
+	names := make([]string, 0, len(e.Failed))
+	for name := range e.Failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return fmt.Sprintf("error sending keyring operation to members: %s", strings.Join(names, ", "))
+}
+
+// Keyring manages the secret keys used to encrypt the gossip
+// traffic across the whole cluster.
+//
+// Operations are applied to the local keyring and then sent to
+// every live member. In order to rotate the primary key without
+// downtime, the new key should be installed first, then used as
+// primary, and finally the old key can be removed.
+type Keyring struct {
+	g  *Gossiper
+	kr *memberlist.Keyring
+}
+
+// Keyring returns the cluster keyring of the Gossiper.
+// If the Gossiper has no secret keys configured returns
+// ErrEncryptionDisabled.
+func (g *Gossiper) Keyring() (*Keyring, error) {
+	if len(g.config.SecretKeys) == 0 {
+		return nil, ErrEncryptionDisabled
+	}
+
+	if _, err := g.memberlist(); err != nil {
+		return nil, err
+	}
+
+	return &Keyring{g: g, kr: g.keyring}, nil
+}
+
+// Install adds the given key to the keyring of every member.
+func (k *Keyring) Install(key []byte) error {
+	return k.apply(keyringMsg{Op: keyringOpInstall, Key: key})
+}
+
+// Use sets the given key, which must be already installed,
+// as the primary key used to encrypt messages on every member.
+func (k *Keyring) Use(key []byte) error {
+	return k.apply(keyringMsg{Op: keyringOpUse, Key: key})
+}
+
+// Remove deletes the given key from the keyring of every member.
+// The primary key can not be removed.
+func (k *Keyring) Remove(key []byte) error {
+	return k.apply(keyringMsg{Op: keyringOpRemove, Key: key})
+}
+
+// Keys returns the keys currently installed in the local keyring,
+// being the first one the primary key.
+func (k *Keyring) Keys() [][]byte {
+	return k.kr.GetKeys()
+}
+
+// PrimaryKey returns the key used to encrypt messages.
+func (k *Keyring) PrimaryKey() []byte {
+	return k.kr.GetPrimaryKey()
+}
+
+// apply applies the given operation to the local keyring and
+// sends it to the rest of members.
+func (k *Keyring) apply(msg keyringMsg) error {
+	if err := applyKeyringMsg(k.kr, msg); err != nil {
+		return err
+	}
+
+	ml, err := k.g.memberlist()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("error encoding keyring operation: %w", err)
+	}
+	buf := encodeMsg(msgKeyring, payload)
+
+	failed := make(map[string]error)
+	for _, n := range k.g.events.liveNodes() {
+		if n.Name == k.g.name {
+			continue
+		}
+		if err := ml.SendReliable(n, buf); err != nil {
+			failed[n.Name] = err
+		}
+	}
+
+	if len(failed) > 0 {
+		return &KeyringError{Failed: failed}
+	}
+
+	return nil
+}
+
+// handleKeyringMsg applies a keyring operation received from
+// another member.
+func (g *Gossiper) handleKeyringMsg(buf []byte) error {
+	if g.keyring == nil {
+		return ErrEncryptionDisabled
+	}
+
+	var msg keyringMsg
+	if err := json.Unmarshal(buf, &msg); err != nil {
+		return fmt.Errorf("error decoding keyring operation: %w", err)
+	}
+
+	return applyKeyringMsg(g.keyring, msg)
+}
+
+func applyKeyringMsg(kr *memberlist.Keyring, msg keyringMsg) error {
+	var err error
+	switch msg.Op {
+	case keyringOpInstall:
+		err = kr.AddKey(msg.Key)
+	case keyringOpUse:
+		err = kr.UseKey(msg.Key)
+	case keyringOpRemove:
+		err = kr.RemoveKey(msg.Key)
+	default:
+		return fmt.Errorf("unknown keyring operation: %d", msg.Op)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error applying keyring operation: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var (
+	testKey0 = []byte("0123456789abcdef")
+	testKey1 = []byte("fedcba9876543210")
+)
+
+func TestGossiperEncryption(t *testing.T) {
+	t.Parallel()
+
+	withKeys := func(keys ...[]byte) func(*GossiperConfig) {
+		return func(c *GossiperConfig) {
+			c.SecretKeys = keys
+		}
+	}
+
+	g0 := newTestGossiper(t, "node0", withKeys(testKey0))
+	g1 := newTestGossiper(t, "node1", withKeys(testKey0))
+	g2 := newTestGossiper(t, "node2")
+	g3 := newTestGossiper(t, "node3", withKeys(testKey1))
+
+	if _, err := g1.Join(context.Background(), []string{gossiperAddr(t, g0)}); err != nil {
+		t.Fatalf("error joining encrypted cluster: %v", err)
+	}
+	if _, err := g2.Join(context.Background(), []string{gossiperAddr(t, g0)}); err == nil {
+		t.Fatal("expected plaintext node not to join encrypted cluster")
+	}
+	if _, err := g0.Join(context.Background(), []string{gossiperAddr(t, g2)}); err == nil {
+		t.Fatal("expected encrypted node not to join plaintext cluster")
+	}
+	if _, err := g3.Join(context.Background(), []string{gossiperAddr(t, g0)}); err == nil {
+		t.Fatal("expected node with a different key not to join encrypted cluster")
+	}
+
+	if _, err := g2.Keyring(); !errors.Is(err, ErrEncryptionDisabled) {
+		t.Fatalf("unexpected error. want: %v but got: %v", ErrEncryptionDisabled, err)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	t.Parallel()
+
+	withKey := func(c *GossiperConfig) {
+		c.SecretKeys = [][]byte{testKey0}
+	}
+
+	g0 := newTestGossiper(t, "node0", withKey)
+	g1 := newTestGossiper(t, "node1", withKey)
+
+	if _, err := g1.Join(context.Background(), []string{gossiperAddr(t, g0)}); err != nil {
+		t.Fatalf("error joining encrypted cluster: %v", err)
+	}
+	waitEvent(t, g0, EventJoin, "node1")
+
+	kr0, err := g0.Keyring()
+	if err != nil {
+		t.Fatalf("error retrieving keyring: %v", err)
+	}
+	kr1, err := g1.Keyring()
+	if err != nil {
+		t.Fatalf("error retrieving keyring: %v", err)
+	}
+
+	if err := kr0.Install(testKey1); err != nil {
+		t.Fatalf("error installing key: %v", err)
+	}
+	waitKeys(t, kr1, testKey0, testKey1)
+
+	if err := kr0.Use(testKey1); err != nil {
+		t.Fatalf("error using key: %v", err)
+	}
+	waitKeys(t, kr1, testKey1, testKey0)
+
+	if err := kr0.Remove(testKey0); err != nil {
+		t.Fatalf("error removing key: %v", err)
+	}
+	waitKeys(t, kr1, testKey1)
+	waitKeys(t, kr0, testKey1)
+}
+
+// waitKeys waits until the given keyring contains exactly the
+// given keys, being the first one the primary key.
+func waitKeys(t *testing.T, kr *Keyring, keys ...[]byte) {
+	t.Helper()
+
+	equal := func() bool {
+		got := kr.Keys()
+		if len(got) != len(keys) || !bytes.Equal(kr.PrimaryKey(), keys[0]) {
+			return false
+		}
+		for _, k := range keys {
+			found := false
+			for _, g := range got {
+				found = found || bytes.Equal(k, g)
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+
+	timeout := time.After(5 * time.Second)
+	for !equal() {
+		select {
+		case <-timeout:
+			t.Fatalf("timeout waiting for keys, got: %q", kr.Keys())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,453 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defKubernetesRetryInterval = time.Second
+
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	serviceNameLabel  = "kubernetes.io/service-name"
+)
+
+// ErrResourceExpired indicates that a watch resource version is too old,
+// so the resources have to be listed again.
+var ErrResourceExpired = errors.New("kubernetes resource version expired")
+
+// KubernetesRemoterConfig defines the Kubernetes service whose
+// EndpointSlices a KubernetesRemoter watches, and how the API
+// server is accessed. Any value which is not set defaults to the
+// in-cluster configuration of the pod service account.
+type KubernetesRemoterConfig struct {
+	// APIServer is the base URL of the API server.
+	APIServer string
+	Namespace string
+	Service   string
+	// PortName is the name of the endpoints port used as member
+	// port. Defaults to the first port of every EndpointSlice.
+	PortName string
+
+	// TokenFile is the file the bearer token is read from before
+	// every request, as service account tokens are rotated.
+	TokenFile string
+	// HTTPClient is the client used to access the API server.
+	// Defaults to one trusting the service account CA.
+	HTTPClient *http.Client
+
+	// RetryInterval is the time waited before listing the endpoints
+	// again after a failure. Defaults to 1s.
+	RetryInterval time.Duration
+
+	// Logger is used by the KubernetesRemoter. Defaults to slog.Default().
+	Logger *slog.Logger
+	// ErrorHandler, if set, is notified of the errors
+	// watching the endpoints in background.
+	ErrorHandler func(error)
+}
+
+// KubernetesRemoter is a Remoter which watches the EndpointSlices of a
+// Kubernetes service through the API server. The ready endpoints are
+// active members, the terminating ones which are still serving are
+// draining members, and any other endpoint is not a member.
+// Members are named after their pod, and tagged with their node and
+// zone, if known.
+type KubernetesRemoter struct {
+	*memberSet
+
+	config KubernetesRemoterConfig
+	logger *slog.Logger
+	client *http.Client
+
+	// slices holds the members of every EndpointSlice,
+	// which is only accessed by the watch goroutine
+	// once the KubernetesRemoter is created.
+	slices map[string][]Member
+}
+
+// NewKubernetesRemoter creates a new KubernetesRemoter for the given
+// config, and lists the endpoints for the first time, returning an
+// error if it fails. The initial members are not delivered as events,
+// but returned by Members. The KubernetesRemoter must be closed once
+// done.
+func NewKubernetesRemoter(ctx context.Context, config KubernetesRemoterConfig) (*KubernetesRemoter, error) {
+	config, err := config.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	kr := &KubernetesRemoter{
+		config: config,
+		logger: logger,
+		client: config.HTTPClient,
+	}
+
+	rv, err := kr.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	kr.memberSet = newMemberSet(kr.members())
+
+	kr.wg.Add(1)
+	go kr.watch(rv)
+
+	return kr, nil
+}
+
+// withDefaults returns the config with the in-cluster
+// defaults for the values which are not set.
+func (c KubernetesRemoterConfig) withDefaults() (KubernetesRemoterConfig, error) {
+	if c.Service == "" {
+		return c, fmt.Errorf("%w: empty service", ErrInvalidConfig)
+	}
+	if c.RetryInterval < 0 {
+		return c, fmt.Errorf("%w: negative retry interval", ErrInvalidConfig)
+	}
+	if c.RetryInterval == 0 {
+		c.RetryInterval = defKubernetesRetryInterval
+	}
+
+	if c.APIServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return c, fmt.Errorf("%w: no API server and not running in a cluster", ErrInvalidConfig)
+		}
+		c.APIServer = "https://" + net.JoinHostPort(host, port)
+	}
+	if c.Namespace == "" {
+		ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return c, fmt.Errorf("%w: no namespace: %v", ErrInvalidConfig, err)
+		}
+		c.Namespace = strings.TrimSpace(string(ns))
+	}
+	if c.HTTPClient == nil {
+		ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+		if err != nil {
+			return c, fmt.Errorf("%w: no HTTP client: %v", ErrInvalidConfig, err)
+		}
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(ca)
+		c.HTTPClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+		}
+		if c.TokenFile == "" {
+			c.TokenFile = serviceAccountDir + "/token"
+		}
+	}
+
+	return c, nil
+}
+
+// Close stops watching the endpoints and closes the events
+// channel. Close is safe to be called multiple times.
+func (kr *KubernetesRemoter) Close() error {
+	kr.memberSet.close()
+	return nil
+}
+
+// watch watches the endpoints from the given resource version until
+// the KubernetesRemoter is closed, listing them again whenever the
+// watch fails.
+func (kr *KubernetesRemoter) watch(rv string) {
+	defer kr.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-kr.done
+		cancel()
+	}()
+
+	for {
+		var err error
+		if rv != "" {
+			rv, err = kr.watchFrom(ctx, rv)
+		} else {
+			rv, err = kr.relist(ctx)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, ErrResourceExpired) {
+			rv = ""
+			continue
+		}
+		if err == nil {
+			// The API server closes watches periodically
+			continue
+		}
+
+		kr.reportErr(err)
+		select {
+		case <-time.After(kr.config.RetryInterval):
+		case <-ctx.Done():
+			return
+		}
+		rv = ""
+	}
+}
+
+// relist lists the endpoints again, updating the members.
+func (kr *KubernetesRemoter) relist(ctx context.Context) (string, error) {
+	rv, err := kr.list(ctx)
+	if err != nil {
+		return "", err
+	}
+	kr.memberSet.update(kr.members())
+
+	return rv, nil
+}
+
+func (kr *KubernetesRemoter) reportErr(err error) {
+	kr.logger.Error("error watching kubernetes endpoints", "service", kr.config.Service, "error", err)
+
+	if kr.config.ErrorHandler != nil {
+		kr.config.ErrorHandler(err)
+	}
+}
+
+// list lists the EndpointSlices of the service,
+// returning the resource version of the list.
+func (kr *KubernetesRemoter) list(ctx context.Context) (string, error) {
+	resp, err := kr.get(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []endpointSlice `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return "", fmt.Errorf("error decoding endpoint slices: %w", err)
+	}
+
+	kr.slices = make(map[string][]Member, len(list.Items))
+	for _, s := range list.Items {
+		kr.slices[s.Metadata.Name] = s.members(kr.config.PortName)
+	}
+
+	return list.Metadata.ResourceVersion, nil
+}
+
+// watchFrom watches the EndpointSlices of the service from the given
+// resource version, applying the changes until the watch is closed.
+// Returns the last resource version seen.
+func (kr *KubernetesRemoter) watchFrom(ctx context.Context, rv string) (string, error) {
+	resp, err := kr.get(ctx, url.Values{
+		"watch":               {"true"},
+		"resourceVersion":     {rv},
+		"allowWatchBookmarks": {"true"},
+	})
+	if err != nil {
+		return rv, err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(resp.Body))
+	for {
+		var e struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return rv, nil
+			}
+			return rv, fmt.Errorf("error decoding watch event: %w", err)
+		}
+
+		if e.Type == "ERROR" {
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			json.Unmarshal(e.Object, &status) //nolint:errcheck
+			if status.Code == http.StatusGone {
+				return rv, ErrResourceExpired
+			}
+			return rv, fmt.Errorf("watch error %d: %s", status.Code, status.Message)
+		}
+
+		var s endpointSlice
+		if err := json.Unmarshal(e.Object, &s); err != nil {
+			return rv, fmt.Errorf("error decoding endpoint slice: %w", err)
+		}
+		rv = s.Metadata.ResourceVersion
+
+		switch e.Type {
+		case "ADDED", "MODIFIED":
+			kr.slices[s.Metadata.Name] = s.members(kr.config.PortName)
+		case "DELETED":
+			delete(kr.slices, s.Metadata.Name)
+		default: // BOOKMARK
+			continue
+		}
+		kr.memberSet.update(kr.members())
+	}
+}
+
+// get requests the EndpointSlices of the service with the given
+// additional query parameters.
+func (kr *KubernetesRemoter) get(ctx context.Context, query url.Values) (*http.Response, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("labelSelector", serviceNameLabel+"="+kr.config.Service)
+
+	u := fmt.Sprintf("%s/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices?%s",
+		strings.TrimSuffix(kr.config.APIServer, "/"), url.PathEscape(kr.config.Namespace), query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error building request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if kr.config.TokenFile != "" {
+		token, err := os.ReadFile(kr.config.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := kr.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting endpoint slices: %w", err)
+	}
+	if resp.StatusCode == http.StatusGone {
+		resp.Body.Close()
+		return nil, ErrResourceExpired
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("error requesting endpoint slices: %s: %s", resp.Status, body)
+	}
+
+	return resp, nil
+}
+
+// members returns the members of all the EndpointSlices. An endpoint
+// in several slices, as during their updates, is a single member,
+// which is active if it is active in any of them.
+func (kr *KubernetesRemoter) members() map[string]Member {
+	members := make(map[string]Member)
+	for _, ms := range kr.slices {
+		for _, m := range ms {
+			if prev, ok := members[m.Name]; ok && !prev.Draining {
+				continue
+			}
+			members[m.Name] = m
+		}
+	}
+
+	return members
+}
+
+// endpointSlice is the subset of the discovery.k8s.io/v1
+// EndpointSlice resource used by the KubernetesRemoter.
+type endpointSlice struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Endpoints []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready       *bool `json:"ready"`
+			Serving     *bool `json:"serving"`
+			Terminating *bool `json:"terminating"`
+		} `json:"conditions"`
+		Hostname  string `json:"hostname"`
+		TargetRef *struct {
+			Name string `json:"name"`
+		} `json:"targetRef"`
+		NodeName string `json:"nodeName"`
+		Zone     string `json:"zone"`
+	} `json:"endpoints"`
+	Ports []struct {
+		Name string `json:"name"`
+		Port *int32 `json:"port"`
+	} `json:"ports"`
+}
+
+// members returns the members of the EndpointSlice
+// using the port with the given name.
+func (s endpointSlice) members(portName string) []Member {
+	var port uint16
+	for _, p := range s.Ports {
+		if p.Port != nil && (p.Name == portName || portName == "") {
+			port = uint16(*p.Port)
+			break
+		}
+	}
+
+	var members []Member
+	for _, e := range s.Endpoints {
+		if len(e.Addresses) == 0 {
+			continue
+		}
+
+		// A nil condition means unknown, and readiness must be
+		// interpreted as ready, as stated by the API reference
+		ready := e.Conditions.Ready == nil || *e.Conditions.Ready
+		serving := e.Conditions.Serving != nil && *e.Conditions.Serving
+		terminating := e.Conditions.Terminating != nil && *e.Conditions.Terminating
+		if !ready && !(serving && terminating) {
+			continue
+		}
+
+		name := e.Addresses[0]
+		switch {
+		case e.TargetRef != nil && e.TargetRef.Name != "":
+			name = e.TargetRef.Name
+		case e.Hostname != "":
+			name = e.Hostname
+		}
+
+		var tags map[string]string
+		for k, v := range map[string]string{"node": e.NodeName, "zone": e.Zone} {
+			if v == "" {
+				continue
+			}
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[k] = v
+		}
+
+		members = append(members, Member{
+			Name:     name,
+			Addr:     net.ParseIP(e.Addresses[0]),
+			Port:     port,
+			Tags:     tags,
+			Draining: !ready,
+		})
+	}
+
+	return members
+}
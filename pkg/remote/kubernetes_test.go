@@ -0,0 +1,251 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fakeAPIServer is a Kubernetes API server serving
+// the list and watch of the EndpointSlices it is set.
+type fakeAPIServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	rv       int
+	slices   map[string]map[string]any
+	history  []map[string]any
+	watchers []chan map[string]any
+}
+
+func newFakeAPIServer(t *testing.T) *fakeAPIServer {
+	t.Helper()
+
+	s := &fakeAPIServer{slices: make(map[string]map[string]any)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// apply applies the given watch event to the slice, delivering
+// it to the active watches. Every event is a resource version.
+func (s *fakeAPIServer) apply(typ string, slice map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rv++
+	slice["metadata"].(map[string]any)["resourceVersion"] = strconv.Itoa(s.rv)
+	name := slice["metadata"].(map[string]any)["name"].(string)
+	if typ == "DELETED" {
+		delete(s.slices, name)
+	} else {
+		s.slices[name] = slice
+	}
+
+	e := map[string]any{"type": typ, "object": slice}
+	s.history = append(s.history, e)
+	for _, w := range s.watchers {
+		w <- e
+	}
+}
+
+// expire expires the active watches, as the API server does once
+// their resource version is compacted.
+func (s *fakeAPIServer) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, w := range s.watchers {
+		w <- map[string]any{"type": "ERROR", "object": map[string]any{"code": http.StatusGone}}
+		close(w)
+	}
+	s.watchers = nil
+}
+
+func (s *fakeAPIServer) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/apis/discovery.k8s.io/v1/namespaces/test/endpointslices" ||
+		r.URL.Query().Get("labelSelector") != serviceNameLabel+"=svc" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	if r.URL.Query().Get("watch") != "true" {
+		items := make([]map[string]any, 0, len(s.slices))
+		for _, slice := range s.slices {
+			items = append(items, slice)
+		}
+		list := map[string]any{
+			"metadata": map[string]any{"resourceVersion": strconv.Itoa(s.rv)},
+			"items":    items,
+		}
+		s.mu.Unlock()
+		json.NewEncoder(w).Encode(list) //nolint:errcheck
+		return
+	}
+
+	// Replay the events after the requested resource version
+	rv, _ := strconv.Atoi(r.URL.Query().Get("resourceVersion"))
+	events := make(chan map[string]any, len(s.history)+10)
+	for _, e := range s.history[rv:] {
+		events <- e
+	}
+	s.watchers = append(s.watchers, events)
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			json.NewEncoder(w).Encode(e) //nolint:errcheck
+			w.(http.Flusher).Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// testEndpointSlice builds an EndpointSlice with an endpoint for every
+// pod name, mapped to its ready and serving conditions. The pod address
+// ends with the last character of its name.
+func testEndpointSlice(name string, pods map[string][2]bool) map[string]any {
+	var endpoints []map[string]any
+	for pod, cond := range pods {
+		endpoints = append(endpoints, map[string]any{
+			"addresses": []string{"10.0.0." + pod[len(pod)-1:]},
+			"conditions": map[string]any{
+				"ready":       cond[0],
+				"serving":     cond[1],
+				"terminating": !cond[0],
+			},
+			"targetRef": map[string]any{"kind": "Pod", "name": pod},
+			"nodeName":  "node-a",
+		})
+	}
+
+	return map[string]any{
+		"metadata":  map[string]any{"name": name},
+		"endpoints": endpoints,
+		"ports": []map[string]any{
+			{"name": "http", "port": 8080},
+			{"name": "gossip", "port": 7946},
+		},
+	}
+}
+
+func TestKubernetesRemoter(t *testing.T) {
+	t.Parallel()
+
+	s := newFakeAPIServer(t)
+	s.apply("ADDED", testEndpointSlice("svc-a", map[string][2]bool{
+		"pod-0": {true, true},
+		"pod-1": {false, false},
+	}))
+
+	kr, err := NewKubernetesRemoter(context.Background(), KubernetesRemoterConfig{
+		APIServer:  s.URL,
+		Namespace:  "test",
+		Service:    "svc",
+		PortName:   "gossip",
+		HTTPClient: s.Client(),
+	})
+	if err != nil {
+		t.Fatalf("error creating kubernetes remoter: %v", err)
+	}
+	t.Cleanup(func() { kr.Close() }) //nolint:errcheck
+
+	members := kr.Members()
+	if len(members) != 1 {
+		t.Fatalf("expected only ready pods to be members, but got %v", members)
+	}
+	if m := members[0]; m.Name != "pod-0" || m.Port != 7946 || m.Tags["node"] != "node-a" || m.Draining {
+		t.Fatalf("unexpected member %+v", m)
+	}
+
+	// Pods becoming ready join, and terminating pods
+	// which are still serving are draining
+	s.apply("MODIFIED", testEndpointSlice("svc-a", map[string][2]bool{
+		"pod-0": {false, true},
+		"pod-1": {true, true},
+	}))
+	waitEvents(t, kr.EventsCh(),
+		Event{Typ: EventUpdate, Name: "pod-0"},
+		Event{Typ: EventJoin, Name: "pod-1"},
+	)
+	if members := kr.Members(); !members[0].Draining || members[1].Draining {
+		t.Fatalf("expected only pod-0 to be draining, but got %v", members)
+	}
+
+	s.apply("ADDED", testEndpointSlice("svc-b", map[string][2]bool{
+		"pod-2": {true, true},
+	}))
+	waitEvents(t, kr.EventsCh(), Event{Typ: EventJoin, Name: "pod-2"})
+
+	s.apply("MODIFIED", testEndpointSlice("svc-a", map[string][2]bool{
+		"pod-1": {true, true},
+	}))
+	waitEvents(t, kr.EventsCh(), Event{Typ: EventLeave, Name: "pod-0"})
+
+	// Changes missed by an expired watch are recovered by relisting
+	s.mu.Lock()
+	delete(s.slices, "svc-b")
+	s.mu.Unlock()
+	s.expire()
+	waitEvents(t, kr.EventsCh(), Event{Typ: EventLeave, Name: "pod-2"})
+
+	s.apply("DELETED", testEndpointSlice("svc-a", nil))
+	waitEvents(t, kr.EventsCh(), Event{Typ: EventLeave, Name: "pod-1"})
+
+	if err := kr.Close(); err != nil {
+		t.Fatalf("error closing kubernetes remoter: %v", err)
+	}
+	if _, ok := <-kr.EventsCh(); ok {
+		t.Fatal("expected events channel to be closed")
+	}
+}
+
+func TestKubernetesRemoterConfig(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		config KubernetesRemoterConfig
+	}{
+		{
+			name:   "no service",
+			config: KubernetesRemoterConfig{APIServer: "http://localhost", Namespace: "test"},
+		},
+		{
+			name: "negative retry interval",
+			config: KubernetesRemoterConfig{
+				APIServer:     "http://localhost",
+				Namespace:     "test",
+				Service:       "svc",
+				HTTPClient:    http.DefaultClient,
+				RetryInterval: -1,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewKubernetesRemoter(context.Background(), tc.config)
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Fatalf("unexpected error. want: %v but got: %v", ErrInvalidConfig, err)
+			}
+		})
+	}
+}
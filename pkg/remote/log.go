@@ -0,0 +1,59 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// memberlistLevels maps the level prefixes of the memberlist
+// log lines to slog levels.
+var memberlistLevels = []struct {
+	prefix string
+	level  slog.Level
+}{
+	{"[DEBUG]", slog.LevelDebug},
+	{"[INFO]", slog.LevelInfo},
+	{"[WARN]", slog.LevelWarn},
+	{"[ERR]", slog.LevelError},
+	{"[ERROR]", slog.LevelError},
+}
+
+// logWriter routes the memberlist internal logs,
+// which are plain text lines, through a slog.Logger.
+type logWriter struct {
+	logger *slog.Logger
+}
+
+func newMemberlistLogger(logger *slog.Logger) *log.Logger {
+	return log.New(&logWriter{logger: logger}, "", 0)
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	level := slog.LevelInfo
+
+	for _, l := range memberlistLevels {
+		if strings.HasPrefix(msg, l.prefix) {
+			msg = strings.TrimSpace(strings.TrimPrefix(msg, l.prefix))
+			level = l.level
+			break
+		}
+	}
+
+	w.logger.Log(context.Background(), level, msg)
+
+	return len(p), nil
+}
+
+// reportErr logs the given error and notifies it to the
+// Gossiper error handler, if any.
+func (g *Gossiper) reportErr(msg string, err error) {
+	g.logger.Error(msg, "error", err)
+
+	if g.config.ErrorHandler != nil {
+		g.config.ErrorHandler(fmt.Errorf("%s: %w", msg, err))
+	}
+}
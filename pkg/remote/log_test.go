@@ -0,0 +1,61 @@
+package remote
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestMemberlistLogger(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		line      string
+		wantLevel string
+		wantMsg   string
+	}{
+		{
+			name:      "should map debug level",
+			line:      "[DEBUG] memberlist: Stream connection",
+			wantLevel: "level=DEBUG",
+			wantMsg:   `msg="memberlist: Stream connection"`,
+		},
+		{
+			name:      "should map warn level",
+			line:      "[WARN] memberlist: Refuting a suspect message",
+			wantLevel: "level=WARN",
+			wantMsg:   `msg="memberlist: Refuting a suspect message"`,
+		},
+		{
+			name:      "should map error level",
+			line:      "[ERR] memberlist: Failed to send",
+			wantLevel: "level=ERROR",
+			wantMsg:   `msg="memberlist: Failed to send"`,
+		},
+		{
+			name:      "should default to info level",
+			line:      "no level",
+			wantLevel: "level=INFO",
+			wantMsg:   `msg="no level"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+			newMemberlistLogger(logger).Println(tc.line)
+
+			out := buf.String()
+			if !strings.Contains(out, tc.wantLevel) || !strings.Contains(out, tc.wantMsg) {
+				t.Fatalf("expected log to contain %s and %s, but got: %s", tc.wantLevel, tc.wantMsg, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package remote
+
+import (
+	"sort"
+	"sync"
+)
+
+// memberSet holds the membership of a Remoter which periodically
+// reads the whole membership from its source, such as a file or DNS,
+// delivering the differences between consecutive reads as events.
+// Remoters tracking the changes of every member, such as Multi,
+// can push their events to the queue while holding its lock.
+type memberSet struct {
+	mu      sync.Mutex
+	members map[string]Member
+	closed  bool
+
+	queue    *eventQueue
+	eventsCh chan Event
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newMemberSet creates a new memberSet with the given initial
+// members, which are not delivered as events, and starts
+// delivering events until it is closed.
+func newMemberSet(members map[string]Member) *memberSet {
+	s := &memberSet{
+		members:  members,
+		queue:    newEventQueue(0, OverflowDropOldest),
+		eventsCh: make(chan Event),
+		done:     make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.queue.run(s.eventsCh, s.done)
+		close(s.eventsCh)
+	}()
+
+	return s
+}
+
+// update replaces the members, delivering the differences as events.
+func (s *memberSet) update(members map[string]Member) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	for _, e := range diffMembers(s.members, members) {
+		s.queue.push(e)
+	}
+	s.members = members
+}
+
+func (s *memberSet) EventsCh() <-chan Event {
+	return s.eventsCh
+}
+
+// Members returns the current members, sorted by name.
+func (s *memberSet) Members() []Member {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortedMembers(s.members)
+}
+
+// close stops delivering events, closes the events channel and waits
+// for the goroutines started in the wait group to finish, which must
+// return once done is closed. Returns false if already closed.
+func (s *memberSet) close() bool {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return false
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	s.queue.close()
+	close(s.done)
+	s.wg.Wait()
+
+	return true
+}
+
+// diffMembers returns the events which lead from
+// the previous members to the current ones.
+func diffMembers(prev, cur map[string]Member) []Event {
+	var events []Event
+	for _, m := range sortedMembers(prev) {
+		if _, ok := cur[m.Name]; !ok {
+			events = append(events, NewMemberEvent(EventLeave, m))
+		}
+	}
+	for _, m := range sortedMembers(cur) {
+		p, ok := prev[m.Name]
+		switch {
+		case !ok:
+			events = append(events, NewMemberEvent(EventJoin, m))
+		case !equalMembers(p, m):
+			events = append(events, NewMemberEvent(EventUpdate, m))
+		}
+	}
+
+	return events
+}
+
+func equalMembers(a, b Member) bool {
+	if a.Name != b.Name || !a.Addr.Equal(b.Addr) || a.Port != b.Port ||
+		a.Weight != b.Weight || a.Draining != b.Draining || a.Incarnation != b.Incarnation ||
+		len(a.Tags) != len(b.Tags) {
+		return false
+	}
+	for k, v := range a.Tags {
+		if bv, ok := b.Tags[k]; !ok || bv != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sortedMembers(members map[string]Member) []Member {
+	sorted := make([]Member, 0, len(members))
+	for _, m := range members {
+		sorted = append(sorted, m)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return sorted
+}
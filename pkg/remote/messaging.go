@@ -0,0 +1,99 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/memberlist"
+)
+
+// bestEffortOverhead is the maximum number of bytes added to a best
+// effort user message until it is sent: the message types, the CRC
+// and the encryption header and padding.
+const bestEffortOverhead = 2 + 5 + 45
+
+var (
+	// ErrUnknownNode indicates that a node is not a live member of the cluster.
+	ErrUnknownNode = errors.New("node is not a cluster member")
+	// ErrMessageTooLarge indicates that a best effort user
+	// message does not fit in a single packet.
+	ErrMessageTooLarge = errors.New("message does not fit in a packet")
+)
+
+// MessageHandler handles the user messages received from other
+// nodes. It is invoked synchronously by the Gossiper transport,
+// so it must not block, and it can keep the given message.
+type MessageHandler func(msg []byte)
+
+// SetMessageHandler sets the handler of the user messages sent by
+// other nodes through SendReliable or SendBestEffort, replacing the
+// previous one. Messages received without a handler are dropped.
+func (g *Gossiper) SetMessageHandler(h MessageHandler) {
+	g.msgHandler.Store(&h)
+}
+
+// SendReliable sends the given user message to the node with the
+// given name using a TCP connection, returning once it is sent.
+func (g *Gossiper) SendReliable(name string, msg []byte) error {
+	return g.send(name, msg, true)
+}
+
+// SendBestEffort sends the given user message to the node with
+// the given name using UDP, so it can be lost. The message must
+// fit in a single packet, otherwise it is rejected returning
+// ErrMessageTooLarge.
+func (g *Gossiper) SendBestEffort(name string, msg []byte) error {
+	return g.send(name, msg, false)
+}
+
+// maxBestEffortSize returns the maximum size of a best effort
+// user message sent by a memberlist with the given config.
+func maxBestEffortSize(c *memberlist.Config) int {
+	size := c.UDPBufferSize - bestEffortOverhead
+	if c.Label != "" {
+		// Label type, length and the label itself
+		size -= 2 + len(c.Label)
+	}
+
+	return size
+}
+
+func (g *Gossiper) send(name string, msg []byte, reliable bool) error {
+	ml, err := g.memberlist()
+	if err != nil {
+		return err
+	}
+
+	if !reliable && len(msg) > g.maxBestEffort {
+		return fmt.Errorf("%w: %d bytes, up to %d", ErrMessageTooLarge, len(msg), g.maxBestEffort)
+	}
+
+	n, ok := g.events.liveNode(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownNode, name)
+	}
+
+	buf := encodeMsg(msgUser, msg)
+	if reliable {
+		err = ml.SendReliable(n, buf)
+	} else {
+		err = ml.SendBestEffort(n, buf)
+	}
+	if err != nil {
+		return fmt.Errorf("error sending message to %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// handleUserMsg delivers the given user message to the
+// message handler, if any.
+func (g *Gossiper) handleUserMsg(msg []byte) {
+	h := g.msgHandler.Load()
+	if h == nil || *h == nil {
+		return
+	}
+
+	// The buffer is owned by memberlist
+	(*h)(append([]byte(nil), msg...))
+}
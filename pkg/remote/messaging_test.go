@@ -0,0 +1,97 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGossiperSend(t *testing.T) {
+	t.Parallel()
+
+	g0 := newTestGossiper(t, "node0")
+	g1 := newTestGossiper(t, "node1")
+
+	received := make(chan []byte, 1)
+	g1.SetMessageHandler(func(msg []byte) {
+		received <- msg
+	})
+
+	if _, err := g1.Join(context.Background(), []string{gossiperAddr(t, g0)}); err != nil {
+		t.Fatalf("error joining cluster: %v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		send    func(name string, msg []byte) error
+		node    string
+		msg     []byte
+		wantErr error
+	}{
+		{
+			name: "should send reliable message",
+			send: g0.SendReliable,
+			node: "node1",
+		},
+		{
+			name: "should send best effort message",
+			send: g0.SendBestEffort,
+			node: "node1",
+		},
+		{
+			name: "should send best effort message of max size",
+			send: g0.SendBestEffort,
+			node: "node1",
+			msg:  bytes.Repeat([]byte{'m'}, g0.maxBestEffort),
+		},
+		{
+			name: "should send large reliable message",
+			send: g0.SendReliable,
+			node: "node1",
+			msg:  bytes.Repeat([]byte{'m'}, 64*1024),
+		},
+		{
+			name:    "should return error on oversized best effort message",
+			send:    g0.SendBestEffort,
+			node:    "node1",
+			msg:     bytes.Repeat([]byte{'m'}, g0.maxBestEffort+1),
+			wantErr: ErrMessageTooLarge,
+		},
+		{
+			name:    "should return error unknown node",
+			send:    g0.SendReliable,
+			node:    "node9",
+			wantErr: ErrUnknownNode,
+		},
+	}
+
+	// Sub tests are not run in parallel as they
+	// share the handler of the receiving node
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := tc.msg
+			if msg == nil {
+				msg = []byte(tc.name)
+			}
+
+			err := tc.send(tc.node, msg)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("unexpected error. want: %v but got: %v", tc.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			select {
+			case got := <-received:
+				if !bytes.Equal(got, msg) {
+					t.Fatalf("expected message to be %q, but got %q", msg, got)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout waiting for message")
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/memberlist"
+)
+
+// metaVersion is the version of the node metadata protocol
+// spoken by the Gossiper.
+const metaVersion = 1
+
+// nodeMeta represents the metadata that each Gossiper announces
+// to the rest of the cluster along with its alive messages.
+type nodeMeta struct {
+	Version uint8             `json:"v"`
+	Cluster string            `json:"c,omitempty"`
+	Tags    map[string]string `json:"t,omitempty"`
+	// Incarnation is set when the Gossiper starts. memberlist does not
+	// expose its own incarnation numbers, which also restart from zero
+	// on each process, so they can not be used to tell apart different
+	// instances of a node with the same name.
+	Incarnation uint64 `json:"i,omitempty"`
+}
+
+func (g *Gossiper) localMeta() nodeMeta {
+	g.metaMu.Lock()
+	defer g.metaMu.Unlock()
+
+	return g.localMetaLocked()
+}
+
+// localMetaLocked returns the local node metadata.
+// Gossiper meta lock must be held before calling this method.
+func (g *Gossiper) localMetaLocked() nodeMeta {
+	return nodeMeta{
+		Version:     metaVersion,
+		Cluster:     g.config.ClusterName,
+		Tags:        g.tags,
+		Incarnation: g.incarnation,
+	}
+}
+
+// validateMetaSize verifies that the encoded metadata fits
+// in the memberlist metadata size limit.
+func validateMetaSize(meta nodeMeta) error {
+	buf, err := encodeMeta(meta)
+	if err != nil {
+		return err
+	}
+	if len(buf) > memberlist.MetaMaxSize {
+		return fmt.Errorf("node metadata of %d bytes exceeds limit of %d", len(buf), memberlist.MetaMaxSize)
+	}
+
+	return nil
+}
+
+func encodeMeta(meta nodeMeta) ([]byte, error) {
+	buf, err := json.Marshal(meta)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding node metadata: %w", err)
+	}
+
+	return buf, nil
+}
+
+func decodeMeta(buf []byte) (nodeMeta, error) {
+	var meta nodeMeta
+	if len(buf) == 0 {
+		return meta, fmt.Errorf("node has no metadata")
+	}
+	if err := json.Unmarshal(buf, &meta); err != nil {
+		return meta, fmt.Errorf("error decoding node metadata: %w", err)
+	}
+
+	return meta, nil
+}
@@ -0,0 +1,258 @@
+package remote
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// MergeUnion takes the attributes of a member from the source
+	// which reported it last, while it keeps reporting it.
+	MergeUnion MergePolicy = iota
+	// MergePriority takes the attributes of a member from the first
+	// source reporting it, in the order the sources are given.
+	MergePriority
+)
+
+// MergePolicy defines how the members reported by several
+// sources are merged. In any case a node is a member while
+// it is reported by at least one source.
+type MergePolicy int
+
+// Multi is a Remoter which combines the membership of several
+// sources, e.g. a static set of members and the ones discovered
+// through gossip, or DNS and gossip during a migration.
+//
+// Members are reference counted by the sources reporting them, so
+// a leave is only delivered once every source has dropped a member,
+// and the attributes of the member are chosen following the merge
+// policy. Events other than membership changes are forwarded as is.
+// Members reported by a source whose events channel is closed are
+// dropped.
+//
+// Multi forwards LocalMember, PublishChecksum, the ring epoch and RTT
+// to the sources implementing them, but does not replicate the ring
+// config.
+type Multi struct {
+	*memberSet
+
+	policy   MergePolicy
+	remoters []Remoter
+
+	// sources holds the members reported by every remoter, and
+	// reported the remoter which last reported every member.
+	// Both are only accessed with the memberSet lock held.
+	sources  []map[string]Member
+	reported map[string]int
+}
+
+// NewMulti creates a new Multi combining the given remoters, which
+// must not be empty, following the given merge policy. The initial
+// members are not delivered as events, but returned by Members.
+// The remoters are owned by the caller, so they are not closed
+// with the Multi, which must be closed once done.
+func NewMulti(policy MergePolicy, remoters ...Remoter) (*Multi, error) {
+	switch policy {
+	case MergeUnion, MergePriority:
+	default:
+		return nil, fmt.Errorf("%w: unknown merge policy %d", ErrInvalidConfig, policy)
+	}
+	if len(remoters) == 0 {
+		return nil, fmt.Errorf("%w: no remoters to merge", ErrInvalidConfig)
+	}
+
+	m := &Multi{
+		policy:   policy,
+		remoters: remoters,
+		sources:  make([]map[string]Member, len(remoters)),
+		reported: make(map[string]int),
+	}
+
+	members := make(map[string]Member)
+	for i, r := range remoters {
+		m.sources[i] = make(map[string]Member)
+		for _, member := range r.Members() {
+			m.sources[i][member.Name] = member
+			m.reported[member.Name] = i
+		}
+	}
+	for name := range m.reported {
+		members[name], _ = m.merge(name)
+	}
+	m.memberSet = newMemberSet(members)
+
+	for i, r := range remoters {
+		m.wg.Add(1)
+		go m.watch(i, r)
+	}
+
+	return m, nil
+}
+
+// Close stops reading the events of the sources and closes
+// the events channel. Close is safe to be called multiple times.
+func (m *Multi) Close() error {
+	m.memberSet.close()
+	return nil
+}
+
+// LocalMember returns the local member known by
+// the first source which is aware of it.
+func (m *Multi) LocalMember() (Member, bool) {
+	for _, r := range m.remoters {
+		if lm, ok := r.(LocalMemberer); ok {
+			if member, ok := lm.LocalMember(); ok {
+				return member, true
+			}
+		}
+	}
+
+	return Member{}, false
+}
+
+// PublishChecksum publishes the checksum through
+// every source able to compare checksums.
+func (m *Multi) PublishChecksum(sum uint64) {
+	for _, r := range m.remoters {
+		if cp, ok := r.(ChecksumPublisher); ok {
+			cp.PublishChecksum(sum)
+		}
+	}
+}
+
+// PublishEpoch publishes the ring epoch through
+// every source replicating the ring epoch.
+func (m *Multi) PublishEpoch(epoch uint64) {
+	for _, r := range m.remoters {
+		if er, ok := r.(EpochReplicator); ok {
+			er.PublishEpoch(epoch)
+		}
+	}
+}
+
+// Epoch returns the greatest ring epoch
+// of the sources replicating the ring epoch.
+func (m *Multi) Epoch() uint64 {
+	var epoch uint64
+	for _, r := range m.remoters {
+		if er, ok := r.(EpochReplicator); ok {
+			epoch = max(epoch, er.Epoch())
+		}
+	}
+
+	return epoch
+}
+
+// RTT returns the round trip time to the given member
+// measured by the first source which measured it.
+func (m *Multi) RTT(name string) (time.Duration, bool) {
+	for _, r := range m.remoters {
+		if rr, ok := r.(RTTReporter); ok {
+			if rtt, ok := rr.RTT(name); ok {
+				return rtt, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// watch applies the events of the given source until the
+// Multi is closed or the source closes its events channel.
+func (m *Multi) watch(i int, r Remoter) {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case e, ok := <-r.EventsCh():
+			if !ok {
+				m.drop(i)
+				return
+			}
+			m.apply(i, e)
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// apply applies an event of the given source.
+func (m *Multi) apply(i int, e Event) {
+	if !isMembershipEvent(e) {
+		m.queue.push(e)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e.Typ == EventLeave {
+		delete(m.sources[i], e.Name)
+	} else {
+		m.sources[i][e.Name] = e.Member()
+		m.reported[e.Name] = i
+	}
+	m.refreshLocked(e.Name)
+}
+
+// drop drops all the members reported by the given source.
+func (m *Multi) drop(i int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members := m.sources[i]
+	m.sources[i] = make(map[string]Member)
+	for name := range members {
+		m.refreshLocked(name)
+	}
+}
+
+// refreshLocked merges the member with the given name from the
+// sources, delivering the change on the merged member, if any.
+// MemberSet lock must be held before calling this method.
+func (m *Multi) refreshLocked(name string) {
+	if m.closed {
+		return
+	}
+
+	member, ok := m.merge(name)
+	if !ok {
+		delete(m.reported, name)
+	}
+
+	prev, had := m.members[name]
+	switch {
+	case ok && !had:
+		m.queue.push(NewMemberEvent(EventJoin, member))
+	case !ok && had:
+		m.queue.push(NewMemberEvent(EventLeave, prev))
+	case ok && !equalMembers(prev, member):
+		m.queue.push(NewMemberEvent(EventUpdate, member))
+	default:
+		return
+	}
+
+	if ok {
+		m.members[name] = member
+	} else {
+		delete(m.members, name)
+	}
+}
+
+// merge returns the member with the given name following the merge
+// policy, or false if no source reports it.
+func (m *Multi) merge(name string) (Member, bool) {
+	if m.policy == MergeUnion {
+		if member, ok := m.sources[m.reported[name]][name]; ok {
+			return member, true
+		}
+	}
+
+	for _, members := range m.sources {
+		if member, ok := members[name]; ok {
+			return member, true
+		}
+	}
+
+	return Member{}, false
+}
@@ -0,0 +1,131 @@
+package remote
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+// chanRemoter is a Remoter delivering the events it is sent.
+type chanRemoter struct {
+	members  []Member
+	eventsCh chan Event
+}
+
+func newChanRemoter(members ...Member) *chanRemoter {
+	return &chanRemoter{members: members, eventsCh: make(chan Event)}
+}
+
+func (r *chanRemoter) EventsCh() <-chan Event {
+	return r.eventsCh
+}
+
+func (r *chanRemoter) Members() []Member {
+	return r.members
+}
+
+func TestMulti(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		policy MergePolicy
+		// wantAddr is the address of srv0, reported by both
+		// sources, once the second one has updated it
+		wantAddr net.IP
+	}{
+		{
+			name:     "union",
+			policy:   MergeUnion,
+			wantAddr: net.IPv4(10, 0, 1, 0),
+		},
+		{
+			name:     "priority",
+			policy:   MergePriority,
+			wantAddr: net.IPv4(10, 0, 0, 0),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			static := newChanRemoter(Member{Name: "srv0", Addr: net.IPv4(10, 0, 0, 0)})
+			gossip := newChanRemoter()
+
+			m, err := NewMulti(tc.policy, static, gossip)
+			if err != nil {
+				t.Fatalf("error creating multi: %v", err)
+			}
+			t.Cleanup(func() { m.Close() }) //nolint:errcheck
+
+			gossip.eventsCh <- Event{Typ: EventJoin, Name: "srv1"}
+			gossip.eventsCh <- Event{Typ: EventJoin, Name: "srv0", Addr: net.IPv4(10, 0, 1, 0)}
+			waitEvents(t, m.EventsCh(), Event{Typ: EventJoin, Name: "srv1"})
+			if tc.policy == MergeUnion {
+				waitEvents(t, m.EventsCh(), Event{Typ: EventUpdate, Name: "srv0"})
+			}
+			if got := m.Members()[0].Addr; !got.Equal(tc.wantAddr) {
+				t.Fatalf("expected srv0 address to be %v, but got %v", tc.wantAddr, got)
+			}
+
+			// Members are kept until every source drops them
+			gossip.eventsCh <- Event{Typ: EventLeave, Name: "srv0"}
+			gossip.eventsCh <- Event{Typ: EventConflict, Name: "srv2"}
+			if tc.policy == MergeUnion {
+				waitEvents(t, m.EventsCh(), Event{Typ: EventUpdate, Name: "srv0"})
+			}
+			waitEvents(t, m.EventsCh(), Event{Typ: EventConflict, Name: "srv2"})
+			if got := len(m.Members()); got != 2 {
+				t.Fatalf("expected members len to be 2, but got %d", got)
+			}
+
+			static.eventsCh <- Event{Typ: EventLeave, Name: "srv0"}
+			waitEvents(t, m.EventsCh(), Event{Typ: EventLeave, Name: "srv0"})
+
+			// Members of closed sources are dropped
+			close(gossip.eventsCh)
+			waitEvents(t, m.EventsCh(), Event{Typ: EventLeave, Name: "srv1"})
+
+			if err := m.Close(); err != nil {
+				t.Fatalf("error closing multi: %v", err)
+			}
+			if _, ok := <-m.EventsCh(); ok {
+				t.Fatal("expected events channel to be closed")
+			}
+		})
+	}
+}
+
+func TestNewMultiErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		policy   MergePolicy
+		remoters []Remoter
+	}{
+		{
+			name:     "unknown policy",
+			policy:   MergePolicy(-1),
+			remoters: []Remoter{newChanRemoter()},
+		},
+		{
+			name:   "no remoters",
+			policy: MergeUnion,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewMulti(tc.policy, tc.remoters...)
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Fatalf("unexpected error. want: %v but got: %v", ErrInvalidConfig, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package remote
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const nodeIDFile = "node-id"
+
+// NodeID returns a unique node identity persisted in the given data
+// directory, generating and storing a new random one on first use.
+// Using it as node name keeps the identity of a node stable across
+// restarts while avoiding name conflicts between different nodes.
+func NodeID(dataDir string) (string, error) {
+	path := filepath.Join(dataDir, nodeIDFile)
+
+	buf, err := os.ReadFile(path)
+	if err == nil {
+		id := strings.TrimSpace(string(buf))
+		if id == "" {
+			return "", fmt.Errorf("empty node id file %s", path)
+		}
+		return id, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("error reading node id: %w", err)
+	}
+
+	id, err := NewNodeID()
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dataDir, 0o700); err != nil {
+		return "", fmt.Errorf("error creating data directory: %w", err)
+	}
+
+	// Write to a temporary file and rename it, so a
+	// partially written id is never read on restart
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(id+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("error writing node id: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("error writing node id: %w", err)
+	}
+
+	return id, nil
+}
+
+// NewNodeID generates a new random node identity.
+func NewNodeID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating node id: %w", err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,36 @@
+package remote
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNodeID(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "data")
+
+	id, err := NodeID(dir)
+	if err != nil {
+		t.Fatalf("error creating node id: %v", err)
+	}
+	if id == "" {
+		t.Fatal("expected node id not to be empty")
+	}
+
+	again, err := NodeID(dir)
+	if err != nil {
+		t.Fatalf("error loading node id: %v", err)
+	}
+	if again != id {
+		t.Fatalf("expected node id to be persisted as %s, but got %s", id, again)
+	}
+
+	other, err := NodeID(t.TempDir())
+	if err != nil {
+		t.Fatalf("error creating node id: %v", err)
+	}
+	if other == id {
+		t.Fatal("expected different data directories to have different node ids")
+	}
+}
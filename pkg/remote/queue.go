@@ -0,0 +1,222 @@
+package remote
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// OverflowDropOldest discards the oldest pending event in order
+	// to make room for the new one.
+	OverflowDropOldest OverflowPolicy = iota
+	// OverflowDropNewest discards the incoming event, keeping the
+	// pending ones untouched.
+	OverflowDropNewest
+)
+
+// OverflowPolicy defines how an event queue behaves when it
+// reaches its capacity.
+type OverflowPolicy int
+
+// QueueStats represents a point in time view of an event queue.
+type QueueStats struct {
+	// Depth is the number of events pending to be delivered.
+	Depth int
+	// Dropped is the total number of events discarded because
+	// of the queue being full.
+	Dropped uint64
+	// Coalesced is the total number of events that replaced a
+	// pending event for the same node.
+	Coalesced uint64
+	// Lag is the time the oldest pending event has been waiting
+	// in the queue.
+	Lag time.Duration
+}
+
+type queuedEvent struct {
+	e        Event
+	enqueued time.Time
+}
+
+// eventQueue is a non blocking queue of events which coalesces
+// membership events per node, so only the latest known state of
+// each node is kept pending for delivery, as well as ring config
+// events, as they carry no data. Any other kind of event is never
+// coalesced.
+type eventQueue struct {
+	mu sync.Mutex
+
+	pending []*queuedEvent
+	byKey   map[coalesceKey]*queuedEvent
+
+	capacity int // 0 means unbounded
+	policy   OverflowPolicy
+
+	dropped   uint64
+	coalesced uint64
+
+	ready  chan struct{}
+	closed bool
+}
+
+func newEventQueue(capacity int, policy OverflowPolicy) *eventQueue {
+	return &eventQueue{
+		byKey:    make(map[coalesceKey]*queuedEvent),
+		capacity: capacity,
+		policy:   policy,
+		ready:    make(chan struct{}, 1),
+	}
+}
+
+// push adds e to the queue without blocking.
+// If an event for the same node is already pending it is replaced
+// by e, which keeps its position in the queue.
+func (q *eventQueue) push(e Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+
+	key, coalescable := coalesceKeyOf(e)
+	if qe, ok := q.byKey[key]; ok && coalescable {
+		// Never replace the pending event by one
+		// of a previous incarnation of the node
+		if e.Incarnation >= qe.e.Incarnation {
+			qe.e = e
+		}
+		q.coalesced++
+		return
+	}
+
+	if q.capacity > 0 && len(q.pending) >= q.capacity {
+		q.dropped++
+		if q.policy == OverflowDropNewest {
+			return
+		}
+		q.removeFirst()
+	}
+
+	qe := &queuedEvent{e: e, enqueued: time.Now()}
+	q.pending = append(q.pending, qe)
+	if coalescable {
+		q.byKey[key] = qe
+	}
+
+	q.signal()
+}
+
+// pop removes and returns the oldest pending event.
+// Returns false if the queue is empty.
+func (q *eventQueue) pop() (Event, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) == 0 {
+		return Event{}, false
+	}
+
+	return q.removeFirst().e, true
+}
+
+// removeFirst removes and returns the oldest pending event.
+// Queue lock must be held and the queue must not be empty
+// before calling this method.
+func (q *eventQueue) removeFirst() *queuedEvent {
+	qe := q.pending[0]
+	q.pending[0] = nil
+	q.pending = q.pending[1:]
+	if key, ok := coalesceKeyOf(qe.e); ok && q.byKey[key] == qe {
+		delete(q.byKey, key)
+	}
+
+	return qe
+}
+
+// run delivers the queued events into out until done is closed.
+func (q *eventQueue) run(out chan<- Event, done <-chan struct{}) {
+	for {
+		e, ok := q.pop()
+		if !ok {
+			select {
+			case <-q.ready:
+				continue
+			case <-done:
+				return
+			}
+		}
+
+		select {
+		case out <- e:
+		case <-done:
+			return
+		}
+	}
+}
+
+// close stops accepting new events.
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+}
+
+func (q *eventQueue) stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	s := QueueStats{
+		Depth:     len(q.pending),
+		Dropped:   q.dropped,
+		Coalesced: q.coalesced,
+	}
+	if len(q.pending) > 0 {
+		s.Lag = time.Since(q.pending[0].enqueued)
+	}
+
+	return s
+}
+
+// signal notifies the delivery loop that there are pending events.
+// Queue lock must be held before calling this method.
+func (q *eventQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// coalesceKey identifies the pending event which an event replaces.
+type coalesceKey struct {
+	name       string
+	ringConfig bool
+	epoch      bool
+}
+
+// coalesceKeyOf returns the coalesce key of e, and false
+// if e must never replace a pending event.
+func coalesceKeyOf(e Event) (coalesceKey, bool) {
+	switch {
+	case isMembershipEvent(e):
+		return coalesceKey{name: e.Name}, true
+	case e.Typ == EventRingConfig:
+		return coalesceKey{ringConfig: true}, true
+	case e.Typ == EventEpoch:
+		return coalesceKey{epoch: true}, true
+	default:
+		return coalesceKey{}, false
+	}
+}
+
+// isMembershipEvent reports whether e represents a change on the
+// state of a node, which supersedes any previous one for that node.
+func isMembershipEvent(e Event) bool {
+	switch e.Typ {
+	case EventJoin, EventLeave, EventUpdate:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,180 @@
+package remote
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventQueuePush(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		capacity      int
+		policy        OverflowPolicy
+		events        []Event
+		wantEvents    []Event
+		wantDropped   uint64
+		wantCoalesced uint64
+	}{
+		{
+			name: "should keep events in order",
+			events: []Event{
+				{Typ: EventJoin, Name: "srv0"},
+				{Typ: EventJoin, Name: "srv1"},
+			},
+			wantEvents: []Event{
+				{Typ: EventJoin, Name: "srv0"},
+				{Typ: EventJoin, Name: "srv1"},
+			},
+		},
+		{
+			name: "should coalesce events for the same node",
+			events: []Event{
+				{Typ: EventJoin, Name: "srv0"},
+				{Typ: EventJoin, Name: "srv1"},
+				{Typ: EventLeave, Name: "srv0"},
+			},
+			wantEvents: []Event{
+				{Typ: EventLeave, Name: "srv0"},
+				{Typ: EventJoin, Name: "srv1"},
+			},
+			wantCoalesced: 1,
+		},
+		{
+			name: "should not coalesce into an older incarnation",
+			events: []Event{
+				{Typ: EventJoin, Name: "srv0", Incarnation: 2},
+				{Typ: EventLeave, Name: "srv0", Incarnation: 1},
+			},
+			wantEvents: []Event{
+				{Typ: EventJoin, Name: "srv0", Incarnation: 2},
+			},
+			wantCoalesced: 1,
+		},
+		{
+			name: "should not coalesce conflict events",
+			events: []Event{
+				{Typ: EventJoin, Name: "srv0"},
+				{Typ: EventConflict, Name: "srv0"},
+				{Typ: EventConflict, Name: "srv0"},
+			},
+			wantEvents: []Event{
+				{Typ: EventJoin, Name: "srv0"},
+				{Typ: EventConflict, Name: "srv0"},
+				{Typ: EventConflict, Name: "srv0"},
+			},
+		},
+		{
+			name: "should coalesce ring config events",
+			events: []Event{
+				{Typ: EventRingConfig},
+				{Typ: EventJoin, Name: "srv0"},
+				{Typ: EventRingConfig},
+			},
+			wantEvents: []Event{
+				{Typ: EventRingConfig},
+				{Typ: EventJoin, Name: "srv0"},
+			},
+			wantCoalesced: 1,
+		},
+		{
+			name: "should coalesce epoch events",
+			events: []Event{
+				{Typ: EventEpoch},
+				{Typ: EventRingConfig},
+				{Typ: EventEpoch},
+			},
+			wantEvents: []Event{
+				{Typ: EventEpoch},
+				{Typ: EventRingConfig},
+			},
+			wantCoalesced: 1,
+		},
+		{
+			name:     "should drop oldest event",
+			capacity: 2,
+			policy:   OverflowDropOldest,
+			events: []Event{
+				{Typ: EventJoin, Name: "srv0"},
+				{Typ: EventJoin, Name: "srv1"},
+				{Typ: EventJoin, Name: "srv2"},
+			},
+			wantEvents: []Event{
+				{Typ: EventJoin, Name: "srv1"},
+				{Typ: EventJoin, Name: "srv2"},
+			},
+			wantDropped: 1,
+		},
+		{
+			name:     "should drop newest event",
+			capacity: 2,
+			policy:   OverflowDropNewest,
+			events: []Event{
+				{Typ: EventJoin, Name: "srv0"},
+				{Typ: EventJoin, Name: "srv1"},
+				{Typ: EventJoin, Name: "srv2"},
+			},
+			wantEvents: []Event{
+				{Typ: EventJoin, Name: "srv0"},
+				{Typ: EventJoin, Name: "srv1"},
+			},
+			wantDropped: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			q := newEventQueue(tc.capacity, tc.policy)
+			for _, e := range tc.events {
+				q.push(e)
+			}
+
+			stats := q.stats()
+			if stats.Depth != len(tc.wantEvents) {
+				t.Fatalf("expected depth to be %d, but got %d", len(tc.wantEvents), stats.Depth)
+			}
+			if stats.Dropped != tc.wantDropped {
+				t.Fatalf("expected dropped to be %d, but got %d", tc.wantDropped, stats.Dropped)
+			}
+			if stats.Coalesced != tc.wantCoalesced {
+				t.Fatalf("expected coalesced to be %d, but got %d", tc.wantCoalesced, stats.Coalesced)
+			}
+
+			var got []Event
+			for e, ok := q.pop(); ok; e, ok = q.pop() {
+				got = append(got, e)
+			}
+			if !reflect.DeepEqual(got, tc.wantEvents) {
+				t.Fatalf("expected events to be %v, but got %v", tc.wantEvents, got)
+			}
+		})
+	}
+}
+
+func TestEventQueueRun(t *testing.T) {
+	t.Parallel()
+
+	q := newEventQueue(0, OverflowDropOldest)
+	out := make(chan Event)
+	done := make(chan struct{})
+	defer close(done)
+
+	go q.run(out, done)
+
+	want := Event{Typ: EventJoin, Name: "srv0"}
+	q.push(want)
+
+	select {
+	case got := <-out:
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected event to be %v, but got %v", want, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+}
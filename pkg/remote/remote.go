@@ -1,10 +1,31 @@
 package remote
 
-import "net"
+import (
+	"net"
+	"time"
+)
 
 const (
 	EventJoin EventType = iota
 	EventLeave
+	// EventUpdate indicates that the attributes of an
+	// existing member, such as its tags, have changed.
+	EventUpdate
+	// EventConflict indicates that a node has been detected using the
+	// name of an existing member. The event refers to the conflicting
+	// node, not to the existing member.
+	EventConflict
+	// EventDivergence indicates that the ring checksum published by
+	// a member has differed from the local one for longer than the
+	// divergence threshold, so their views of the ring diverge.
+	EventDivergence
+	// EventRingConfig indicates that the replicated ring config has
+	// changed. The event does not refer to any member.
+	EventRingConfig
+	// EventEpoch indicates that a member has published a ring epoch
+	// greater than any seen before. The event does not refer to any
+	// member.
+	EventEpoch
 )
 
 type EventType int
@@ -14,8 +35,119 @@ type Event struct {
 	Name string
 	Addr net.IP
 	Port uint16
+	Tags map[string]string
+	// Incarnation identifies the instance of the node, being greater
+	// for newer instances of a node with the same name. Zero means
+	// that the incarnation is unknown.
+	Incarnation uint64
+	// Weight is the weight of the member. See Member.Weight.
+	Weight float64
+	// Draining reports whether the member is draining.
+	// See Member.Draining.
+	Draining bool
+}
+
+// Member returns the member which the event refers to.
+func (e Event) Member() Member {
+	return Member{
+		Name:        e.Name,
+		Addr:        e.Addr,
+		Port:        e.Port,
+		Tags:        e.Tags,
+		Incarnation: e.Incarnation,
+		Weight:      e.Weight,
+		Draining:    e.Draining,
+	}
+}
+
+// NewMemberEvent returns an event of the given type for the given
+// member, being the inverse of Event.Member.
+func NewMemberEvent(typ EventType, m Member) Event {
+	return Event{
+		Typ:         typ,
+		Name:        m.Name,
+		Addr:        m.Addr,
+		Port:        m.Port,
+		Tags:        m.Tags,
+		Incarnation: m.Incarnation,
+		Weight:      m.Weight,
+		Draining:    m.Draining,
+	}
+}
+
+// MaxWeight is the maximum weight of a member, which bounds the
+// number of replicas it has in the ring.
+const MaxWeight = 100
+
+// Member represents a node which is part of the remote membership.
+type Member struct {
+	Name string
+	Addr net.IP
+	Port uint16
+	// Tags are arbitrary key/value pairs announced by the member,
+	// such as its role, which can be used to filter members.
+	Tags map[string]string
+	// Incarnation identifies the instance of the member.
+	// See Event.Incarnation.
+	Incarnation uint64
+	// Weight scales the share of keys owned by the member in the
+	// ring, up to MaxWeight. Zero means the default weight of 1.
+	Weight float64
+	// Draining members are about to leave, so they are kept as
+	// ring members but do not own any key.
+	Draining bool
+}
+
+// LocalMemberer is implemented by the Remoters which are
+// aware of the member representing the local node.
+type LocalMemberer interface {
+	// LocalMember returns the local member, or false
+	// if it is not known yet.
+	LocalMember() (Member, bool)
+}
+
+// ChecksumPublisher is implemented by the Remoters able to compare
+// the local view of the ring with the one of the other members.
+type ChecksumPublisher interface {
+	// PublishChecksum sets the checksum of the local view of the ring.
+	PublishChecksum(sum uint64)
+}
+
+// EpochReplicator is implemented by the Remoters which replicate the
+// ring epoch, so every member advances its epoch to the greatest one
+// in the cluster before bumping it, and the epochs of the members are
+// comparable. Greater epochs are notified with an EventEpoch.
+type EpochReplicator interface {
+	// PublishEpoch sets the ring epoch of the local node.
+	PublishEpoch(epoch uint64)
+	// Epoch returns the greatest ring epoch published by any member.
+	Epoch() uint64
+}
+
+// RingConfigReplicator is implemented by the Remoters which replicate
+// the ring config, a map of arbitrary keys and values, across the
+// cluster. Changes are notified with an EventRingConfig.
+type RingConfigReplicator interface {
+	// RingConfig returns the current ring config.
+	RingConfig() map[string]string
+	// SetRingConfig sets the value of the given key.
+	SetRingConfig(key, value string) error
+	// DeleteRingConfig deletes the given key.
+	DeleteRingConfig(key string) error
+}
+
+// RTTReporter is implemented by the Remoters which measure
+// the round trip time to the members.
+type RTTReporter interface {
+	// RTT returns the round trip time to the member with the
+	// given name, or false if it has not been measured.
+	RTT(name string) (time.Duration, bool)
 }
 
 type Remoter interface {
+	// EventsCh returns the channel on which membership
+	// changes are delivered.
 	EventsCh() <-chan Event
+	// Members returns a snapshot of the current membership.
+	Members() []Member
 }
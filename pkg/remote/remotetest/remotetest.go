@@ -0,0 +1,237 @@
+// Package remotetest provides a fake remote.Remoter, so code using
+// remote membership can be tested without a real cluster.
+package remotetest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/ka3de/consistent/pkg/remote"
+)
+
+// ErrClosed indicates that the Remoter has been closed.
+var ErrClosed = errors.New("remoter is closed")
+
+// Remoter is an in-memory remote.Remoter whose membership is scripted
+// by the test. Events are delivered in the order they are pushed,
+// without blocking the caller, until the Remoter is closed. Flush
+// waits until the consumer has received them.
+type Remoter struct {
+	mu      sync.Mutex
+	members map[string]remote.Member
+	local   string
+	pending []remote.Event
+	closed  bool
+	ready   chan struct{}
+
+	// pushed and delivered count the events, and delivery is
+	// closed and replaced every time an event is delivered
+	pushed    uint64
+	delivered uint64
+	delivery  chan struct{}
+
+	eventsCh chan remote.Event
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+// NewRemoter creates a new Remoter with the given initial members,
+// which are not delivered as events. The Remoter must be closed
+// once done.
+func NewRemoter(members ...remote.Member) *Remoter {
+	r := &Remoter{
+		members:  make(map[string]remote.Member, len(members)),
+		ready:    make(chan struct{}, 1),
+		delivery: make(chan struct{}),
+		eventsCh: make(chan remote.Event),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	for _, m := range members {
+		r.members[m.Name] = m
+	}
+
+	go r.run()
+
+	return r
+}
+
+func (r *Remoter) EventsCh() <-chan remote.Event {
+	return r.eventsCh
+}
+
+// Members returns the current members, sorted by name, including
+// the changes pushed which have not been delivered yet.
+func (r *Remoter) Members() []remote.Member {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	members := make([]remote.Member, 0, len(r.members))
+	for _, m := range r.members {
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+
+	return members
+}
+
+// SetLocal sets the name of the member representing the local node,
+// reported by LocalMember while it is a member.
+func (r *Remoter) SetLocal(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.local = name
+}
+
+// LocalMember returns the local member, or false if it
+// is not set or it is not a member.
+func (r *Remoter) LocalMember() (remote.Member, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.members[r.local]
+	return m, ok
+}
+
+// Join adds the given member, delivering an EventJoin.
+func (r *Remoter) Join(m remote.Member) {
+	r.Push(remote.NewMemberEvent(remote.EventJoin, m))
+}
+
+// Update replaces the given member, delivering an EventUpdate.
+func (r *Remoter) Update(m remote.Member) {
+	r.Push(remote.NewMemberEvent(remote.EventUpdate, m))
+}
+
+// Leave removes the member with the given name, delivering an
+// EventLeave for it.
+func (r *Remoter) Leave(name string) {
+	r.mu.Lock()
+	m, ok := r.members[name]
+	r.mu.Unlock()
+	if !ok {
+		m = remote.Member{Name: name}
+	}
+
+	r.Push(remote.NewMemberEvent(remote.EventLeave, m))
+}
+
+// Push delivers the given event as is, applying it to the members
+// if it is a join, leave or update. It allows to deliver events
+// which a real Remoter would not, such as a leave for a node which
+// is not a member. Events pushed after Close are discarded.
+func (r *Remoter) Push(e remote.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+
+	switch e.Typ {
+	case remote.EventJoin, remote.EventUpdate:
+		r.members[e.Name] = e.Member()
+	case remote.EventLeave:
+		delete(r.members, e.Name)
+	}
+
+	r.pending = append(r.pending, e)
+	r.pushed++
+	select {
+	case r.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Flush blocks until the consumer has received every event pushed
+// before calling it, or the context is done. The consumer may not
+// have applied the last event yet, so rings should be synced with
+// consistenttest.Sync instead.
+// Returns ErrClosed if the Remoter is closed before.
+func (r *Remoter) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pushed := r.pushed
+	for r.delivered < pushed {
+		if r.closed {
+			return ErrClosed
+		}
+
+		delivery := r.delivery
+		r.mu.Unlock()
+		select {
+		case <-delivery:
+		case <-r.stopped:
+		case <-ctx.Done():
+			r.mu.Lock()
+			return ctx.Err()
+		}
+		r.mu.Lock()
+	}
+
+	return nil
+}
+
+// Close stops delivering events and closes the events channel,
+// which the consumer of the Remoter reads as the remote having
+// stopped. Close is safe to be called multiple times.
+func (r *Remoter) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.mu.Unlock()
+
+	close(r.done)
+	<-r.stopped
+
+	return nil
+}
+
+// run delivers the pending events until the Remoter is closed.
+func (r *Remoter) run() {
+	defer close(r.stopped)
+	defer close(r.eventsCh)
+
+	for {
+		r.mu.Lock()
+		var (
+			e  remote.Event
+			ok = len(r.pending) > 0
+		)
+		if ok {
+			e = r.pending[0]
+			r.pending = r.pending[1:]
+		}
+		r.mu.Unlock()
+
+		if !ok {
+			select {
+			case <-r.ready:
+				continue
+			case <-r.done:
+				return
+			}
+		}
+
+		select {
+		case r.eventsCh <- e:
+		case <-r.done:
+			return
+		}
+
+		r.mu.Lock()
+		r.delivered++
+		close(r.delivery)
+		r.delivery = make(chan struct{})
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,115 @@
+package remotetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ka3de/consistent/pkg/remote"
+)
+
+func TestRemoter(t *testing.T) {
+	t.Parallel()
+
+	r := NewRemoter(remote.Member{Name: "srv0"})
+	r.SetLocal("srv1")
+
+	if _, ok := r.LocalMember(); ok {
+		t.Fatal("expected local member not to be known before joining")
+	}
+
+	// Events are queued until they are read
+	r.Join(remote.Member{Name: "srv1", Weight: 2})
+	r.Update(remote.Member{Name: "srv1", Weight: 3})
+	r.Leave("srv0")
+	r.Push(remote.Event{Typ: remote.EventConflict, Name: "srv2"})
+
+	if local, ok := r.LocalMember(); !ok || local.Weight != 3 {
+		t.Fatalf("expected local member srv1 with weight 3, but got %v", local)
+	}
+	if got := r.Members(); !reflect.DeepEqual(got, []remote.Member{{Name: "srv1", Weight: 3}}) {
+		t.Fatalf("unexpected members %v", got)
+	}
+
+	want := []remote.EventType{remote.EventJoin, remote.EventUpdate, remote.EventLeave, remote.EventConflict}
+	for _, w := range want {
+		select {
+		case e := <-r.EventsCh():
+			if e.Typ != w {
+				t.Fatalf("expected event %v, but got %v", w, e.Typ)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for event %v", w)
+		}
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("error closing remoter: %v", err)
+	}
+	if _, ok := <-r.EventsCh(); ok {
+		t.Fatal("expected events channel to be closed")
+	}
+	r.Join(remote.Member{Name: "srv2"})
+}
+
+func TestRemoterFlush(t *testing.T) {
+	t.Parallel()
+
+	r := NewRemoter()
+	t.Cleanup(func() { r.Close() }) //nolint:errcheck
+
+	// Without events there is nothing to wait for
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error. want: %v but got: %v", nil, err)
+	}
+
+	r.Join(remote.Member{Name: "srv0"})
+	r.Join(remote.Member{Name: "srv1"})
+
+	// Events not read by the consumer block the flush
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := r.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error. want: %v but got: %v", context.DeadlineExceeded, err)
+	}
+
+	flushed := make(chan error, 1)
+	go func() { flushed <- r.Flush(context.Background()) }()
+
+	for _, name := range []string{"srv0", "srv1"} {
+		select {
+		case e := <-r.EventsCh():
+			if e.Name != name {
+				t.Fatalf("expected event for %s, but got %v", name, e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for event for %s", name)
+		}
+	}
+
+	select {
+	case err := <-flushed:
+		if err != nil {
+			t.Fatalf("unexpected error. want: %v but got: %v", nil, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for flush")
+	}
+
+	// Events which will never be delivered fail the flush on close
+	r.Join(remote.Member{Name: "srv2"})
+	go func() { flushed <- r.Flush(context.Background()) }()
+	if err := r.Close(); err != nil {
+		t.Fatalf("error closing remoter: %v", err)
+	}
+	select {
+	case err := <-flushed:
+		if !errors.Is(err, ErrClosed) {
+			t.Fatalf("unexpected error. want: %v but got: %v", ErrClosed, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for flush")
+	}
+}
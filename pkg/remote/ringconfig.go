@@ -0,0 +1,242 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/memberlist"
+)
+
+const (
+	defRingConfigTombstoneTTL = 24 * time.Hour
+	// ringConfigPruneInterval is the maximum interval on which
+	// the expired tombstones of the ring config are removed.
+	ringConfigPruneInterval = time.Minute
+)
+
+// ErrEmptyKey indicates that a ring config key is empty.
+var ErrEmptyKey = errors.New("ring config key is empty")
+
+// RingConfigEntry is a versioned value of the replicated ring config.
+// Entries are ordered by their Lamport clock, using the name of the
+// node which wrote them to break ties, and the greatest one wins.
+type RingConfigEntry struct {
+	Value string `json:"v,omitempty"`
+	Clock uint64 `json:"c"`
+	Node  string `json:"n"`
+	// Deleted marks the entry as a tombstone, which is kept for the
+	// tombstone TTL so the deletion wins over older values still
+	// known by other nodes.
+	Deleted bool `json:"d,omitempty"`
+}
+
+// newerThan reports whether the entry wins over the given one.
+func (e RingConfigEntry) newerThan(other RingConfigEntry) bool {
+	if e.Clock != other.Clock {
+		return e.Clock > other.Clock
+	}
+	return e.Node > other.Node
+}
+
+// ringConfig is a last-writer-wins map, which converges to the
+// same state in every node regardless of the order in which the
+// entries are merged.
+type ringConfig struct {
+	mu      sync.Mutex
+	entries map[string]RingConfigEntry
+	clock   uint64
+	// deletedAt holds when each tombstone was stored locally.
+	deletedAt map[string]time.Time
+}
+
+func newRingConfig() *ringConfig {
+	return &ringConfig{
+		entries:   make(map[string]RingConfigEntry),
+		deletedAt: make(map[string]time.Time),
+	}
+}
+
+// store stores the entry for the given key, tracking its tombstone.
+// Ring config lock must be held before calling this method.
+func (rc *ringConfig) store(key string, e RingConfigEntry) {
+	rc.entries[key] = e
+	if e.Deleted {
+		rc.deletedAt[key] = time.Now()
+	} else {
+		delete(rc.deletedAt, key)
+	}
+}
+
+// set writes a new entry for the given key, with a clock greater
+// than any one seen so far, and returns it.
+func (rc *ringConfig) set(key, value, node string, deleted bool) RingConfigEntry {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.clock++
+	e := RingConfigEntry{Value: value, Clock: rc.clock, Node: node, Deleted: deleted}
+	rc.store(key, e)
+
+	return e
+}
+
+// merge merges the given entries, keeping the newest
+// one for each key. Reports whether anything changed.
+func (rc *ringConfig) merge(entries map[string]RingConfigEntry) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	changed := false
+	for k, e := range entries {
+		if e.Clock > rc.clock {
+			rc.clock = e.Clock
+		}
+		if cur, ok := rc.entries[k]; ok && !e.newerThan(cur) {
+			continue
+		}
+		rc.store(k, e)
+		changed = true
+	}
+
+	return changed
+}
+
+// prune removes the tombstones stored before the given time,
+// returning the number of removed ones. The clock is kept, so
+// new writes still win over the values which were deleted.
+func (rc *ringConfig) prune(before time.Time) int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	n := 0
+	for k, t := range rc.deletedAt {
+		if t.Before(before) {
+			delete(rc.entries, k)
+			delete(rc.deletedAt, k)
+			n++
+		}
+	}
+
+	return n
+}
+
+// values returns the current values, excluding the deleted ones.
+func (rc *ringConfig) values() map[string]string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	values := make(map[string]string, len(rc.entries))
+	for k, e := range rc.entries {
+		if !e.Deleted {
+			values[k] = e.Value
+		}
+	}
+
+	return values
+}
+
+// encode returns the full config, to be sent on a push/pull.
+func (rc *ringConfig) encode() ([]byte, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return json.Marshal(rc.entries)
+}
+
+// ringConfigBroadcast is a broadcast of a single ring config
+// entry, which invalidates previous ones for the same key.
+type ringConfigBroadcast struct {
+	key string
+	msg []byte
+}
+
+func (b *ringConfigBroadcast) Invalidates(other memberlist.Broadcast) bool {
+	o, ok := other.(*ringConfigBroadcast)
+	return ok && o.key == b.key
+}
+
+func (b *ringConfigBroadcast) Message() []byte {
+	return b.msg
+}
+
+func (b *ringConfigBroadcast) Finished() {}
+
+// RingConfig returns the current replicated ring config.
+func (g *Gossiper) RingConfig() map[string]string {
+	return g.ringConfig.values()
+}
+
+// SetRingConfig sets the value of the given key of the ring config,
+// which is replicated to the whole cluster.
+func (g *Gossiper) SetRingConfig(key, value string) error {
+	return g.writeRingConfig(key, value, false)
+}
+
+// DeleteRingConfig deletes the given key of the ring config,
+// which is replicated to the whole cluster.
+func (g *Gossiper) DeleteRingConfig(key string) error {
+	return g.writeRingConfig(key, "", true)
+}
+
+func (g *Gossiper) writeRingConfig(key, value string, deleted bool) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	if _, err := g.memberlist(); err != nil {
+		return err
+	}
+
+	e := g.ringConfig.set(key, value, g.name, deleted)
+
+	// The entry is broadcast for a faster propagation, although
+	// push/pull syncs guarantee that every node eventually gets it
+	payload, err := json.Marshal(map[string]RingConfigEntry{key: e})
+	if err != nil {
+		return fmt.Errorf("error encoding ring config message: %w", err)
+	}
+	g.queueBroadcast(&ringConfigBroadcast{key: key, msg: encodeMsg(msgRingConfig, payload)})
+	g.events.push(Event{Typ: EventRingConfig})
+
+	return nil
+}
+
+// handleRingConfigMsg merges the ring config entries received
+// from another node, notifying if the ring config changed.
+func (g *Gossiper) handleRingConfigMsg(payload []byte) error {
+	var entries map[string]RingConfigEntry
+	if err := json.Unmarshal(payload, &entries); err != nil {
+		return fmt.Errorf("error decoding ring config: %w", err)
+	}
+
+	if g.ringConfig.merge(entries) {
+		g.events.push(Event{Typ: EventRingConfig})
+	}
+
+	return nil
+}
+
+// pruneRingConfig periodically removes the ring config tombstones
+// older than the tombstone TTL, so they are no longer exchanged on
+// every push/pull, until the Gossiper is shut down.
+func (g *Gossiper) pruneRingConfig() {
+	ttl := g.config.RingConfigTombstoneTTL
+	if ttl <= 0 {
+		ttl = defRingConfigTombstoneTTL
+	}
+
+	ticker := time.NewTicker(min(ttl, ringConfigPruneInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-g.ctx.Done():
+			return
+		}
+
+		g.ringConfig.prune(time.Now().Add(-ttl))
+	}
+}
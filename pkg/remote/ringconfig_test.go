@@ -0,0 +1,187 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRingConfigMerge(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		local       map[string]RingConfigEntry
+		remote      map[string]RingConfigEntry
+		wantValues  map[string]string
+		wantChanged bool
+	}{
+		{
+			name:        "should add new keys",
+			remote:      map[string]RingConfigEntry{"k": {Value: "v", Clock: 1, Node: "n1"}},
+			wantValues:  map[string]string{"k": "v"},
+			wantChanged: true,
+		},
+		{
+			name:        "should keep newest clock",
+			local:       map[string]RingConfigEntry{"k": {Value: "new", Clock: 2, Node: "n0"}},
+			remote:      map[string]RingConfigEntry{"k": {Value: "old", Clock: 1, Node: "n1"}},
+			wantValues:  map[string]string{"k": "new"},
+			wantChanged: false,
+		},
+		{
+			name:        "should break ties by node name",
+			local:       map[string]RingConfigEntry{"k": {Value: "v0", Clock: 1, Node: "n0"}},
+			remote:      map[string]RingConfigEntry{"k": {Value: "v1", Clock: 1, Node: "n1"}},
+			wantValues:  map[string]string{"k": "v1"},
+			wantChanged: true,
+		},
+		{
+			name:        "should apply newer deletions",
+			local:       map[string]RingConfigEntry{"k": {Value: "v", Clock: 1, Node: "n0"}},
+			remote:      map[string]RingConfigEntry{"k": {Clock: 2, Node: "n1", Deleted: true}},
+			wantValues:  map[string]string{},
+			wantChanged: true,
+		},
+		{
+			name:        "should ignore older values of deleted keys",
+			local:       map[string]RingConfigEntry{"k": {Clock: 2, Node: "n0", Deleted: true}},
+			remote:      map[string]RingConfigEntry{"k": {Value: "v", Clock: 1, Node: "n1"}},
+			wantValues:  map[string]string{},
+			wantChanged: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rc := newRingConfig()
+			rc.merge(tc.local)
+
+			if changed := rc.merge(tc.remote); changed != tc.wantChanged {
+				t.Fatalf("expected changed to be %v, but got %v", tc.wantChanged, changed)
+			}
+			if values := rc.values(); !reflect.DeepEqual(values, tc.wantValues) {
+				t.Fatalf("expected values to be %v, but got %v", tc.wantValues, values)
+			}
+
+			// Writes after a merge must win over any merged entry
+			e := rc.set("k", "local", "n0", false)
+			for _, re := range tc.remote {
+				if !e.newerThan(re) {
+					t.Fatalf("expected local entry %v to be newer than %v", e, re)
+				}
+			}
+		})
+	}
+}
+
+func TestRingConfigPrune(t *testing.T) {
+	t.Parallel()
+
+	rc := newRingConfig()
+	rc.set("deleted", "", "n0", true)
+	rc.set("live", "v", "n0", false)
+	rc.set("restored", "", "n0", true)
+	rc.set("restored", "v", "n0", false)
+
+	if n := rc.prune(time.Now().Add(-time.Hour)); n != 0 {
+		t.Fatalf("expected no tombstone to be pruned before the TTL, but got %d", n)
+	}
+	if n := rc.prune(time.Now().Add(time.Second)); n != 1 {
+		t.Fatalf("expected 1 tombstone to be pruned, but got %d", n)
+	}
+
+	buf, err := rc.encode()
+	if err != nil {
+		t.Fatalf("unexpected error. want: %v but got: %v", nil, err)
+	}
+	var entries map[string]RingConfigEntry
+	if err := json.Unmarshal(buf, &entries); err != nil {
+		t.Fatalf("unexpected error. want: %v but got: %v", nil, err)
+	}
+	if _, ok := entries["deleted"]; ok {
+		t.Fatalf("expected pruned tombstone not to be encoded, but got %v", entries)
+	}
+	want := map[string]string{"live": "v", "restored": "v"}
+	if values := rc.values(); !reflect.DeepEqual(values, want) {
+		t.Fatalf("expected values to be %v, but got %v", want, values)
+	}
+
+	// Writes after pruning must still win over the deleted value
+	if e := rc.set("deleted", "v", "n0", false); e.Clock <= 1 {
+		t.Fatalf("expected clock to be kept after pruning, but got %d", e.Clock)
+	}
+}
+
+func TestGossiperRingConfigTombstoneTTL(t *testing.T) {
+	t.Parallel()
+
+	g := newTestGossiper(t, "node0", func(c *GossiperConfig) {
+		c.RingConfigTombstoneTTL = 50 * time.Millisecond
+	})
+
+	if err := g.SetRingConfig("weight/node0", "2"); err != nil {
+		t.Fatalf("error setting ring config: %v", err)
+	}
+	if err := g.DeleteRingConfig("weight/node0"); err != nil {
+		t.Fatalf("error deleting ring config: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		g.ringConfig.mu.Lock()
+		n := len(g.ringConfig.entries)
+		g.ringConfig.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected tombstone to be pruned after the TTL")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGossiperRingConfig(t *testing.T) {
+	t.Parallel()
+
+	pushPull := func(c *GossiperConfig) {
+		c.PushPullInterval = 100 * time.Millisecond
+	}
+	g0 := newTestGossiper(t, "node0", pushPull)
+	g1 := newTestGossiper(t, "node1", pushPull)
+
+	if err := g0.SetRingConfig("weight/node0", "2"); err != nil {
+		t.Fatalf("error setting ring config: %v", err)
+	}
+
+	// The config set before joining is synced on join
+	if _, err := g1.Join(context.Background(), []string{gossiperAddr(t, g0)}); err != nil {
+		t.Fatalf("error joining cluster: %v", err)
+	}
+	waitEvent(t, g1, EventRingConfig, "")
+	if config := g1.RingConfig(); config["weight/node0"] != "2" {
+		t.Fatalf("expected ring config to be synced, but got %v", config)
+	}
+
+	if err := g1.DeleteRingConfig("weight/node0"); err != nil {
+		t.Fatalf("error deleting ring config: %v", err)
+	}
+	waitEvent(t, g0, EventRingConfig, "")
+	deadline := time.Now().Add(5 * time.Second)
+	for len(g0.RingConfig()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected ring config to be deleted, but got %v", g0.RingConfig())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := g0.SetRingConfig("", "v"); err != ErrEmptyKey {
+		t.Fatalf("unexpected error. want: %v but got: %v", ErrEmptyKey, err)
+	}
+}
@@ -0,0 +1,101 @@
+package remote
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hashicorp/memberlist"
+)
+
+const (
+	// rttWeight is the weight of a new sample in the smoothed RTT.
+	rttWeight = 0.2
+	// rttPruneInterval is the interval on which the RTTs
+	// of the nodes which are no longer members are forgotten.
+	rttPruneInterval = time.Minute
+)
+
+// gossipPing implements the memberlist.PingDelegate interface in
+// order to collect the round trip times measured by the probes.
+type gossipPing struct {
+	g *Gossiper
+}
+
+// AckPayload is invoked when an ack is being sent.
+func (p *gossipPing) AckPayload() []byte {
+	return nil
+}
+
+// NotifyPingComplete is invoked when an ack for a ping is received.
+func (p *gossipPing) NotifyPingComplete(other *memberlist.Node, rtt time.Duration, payload []byte) {
+	p.g.rtts.observe(other.Name, rtt)
+}
+
+// rtts holds the smoothed round trip time to each node.
+type rtts struct {
+	mu     sync.Mutex
+	byName map[string]time.Duration
+}
+
+// observe records a new RTT sample for the given node, smoothing
+// it with an exponentially weighted moving average so a single
+// slow probe does not change the measured RTT much.
+func (r *rtts) observe(name string, rtt time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, ok := r.byName[name]
+	if !ok {
+		r.byName[name] = rtt
+		return
+	}
+	r.byName[name] = prev + time.Duration(rttWeight*float64(rtt-prev))
+}
+
+func (r *rtts) get(name string) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rtt, ok := r.byName[name]
+	return rtt, ok
+}
+
+// prune forgets the RTTs of the nodes which are not in the given set.
+func (r *rtts) prune(members map[string]bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name := range r.byName {
+		if !members[name] {
+			delete(r.byName, name)
+		}
+	}
+}
+
+// RTT returns the smoothed round trip time to the node with the
+// given name, as measured by the direct probes of the failure
+// detector. Returns false if it has not been measured yet.
+func (g *Gossiper) RTT(name string) (time.Duration, bool) {
+	return g.rtts.get(name)
+}
+
+// pruneRTTs periodically forgets the RTTs of the nodes which
+// are no longer members, until the Gossiper is shut down.
+func (g *Gossiper) pruneRTTs() {
+	ticker := time.NewTicker(rttPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-g.ctx.Done():
+			return
+		}
+
+		members := make(map[string]bool)
+		for _, n := range g.events.liveNodes() {
+			members[n.Name] = true
+		}
+		g.rtts.prune(members)
+	}
+}
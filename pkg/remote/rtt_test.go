@@ -0,0 +1,87 @@
+package remote
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRTTObserve(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		samples []time.Duration
+		want    time.Duration
+	}{
+		{
+			name:    "should use first sample",
+			samples: []time.Duration{100},
+			want:    100,
+		},
+		{
+			name:    "should smooth samples",
+			samples: []time.Duration{100, 200},
+			want:    120,
+		},
+		{
+			name:    "should smooth decreasing samples",
+			samples: []time.Duration{100, 50},
+			want:    90,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &rtts{byName: make(map[string]time.Duration)}
+			for _, s := range tc.samples {
+				r.observe("node0", s)
+			}
+
+			if got, _ := r.get("node0"); got != tc.want {
+				t.Fatalf("expected rtt to be %v, but got %v", tc.want, got)
+			}
+
+			r.prune(map[string]bool{"node1": true})
+			if _, ok := r.get("node0"); ok {
+				t.Fatal("expected rtt of non member to be pruned")
+			}
+		})
+	}
+}
+
+func TestGossiperRTT(t *testing.T) {
+	t.Parallel()
+
+	probe := func(c *GossiperConfig) {
+		c.ProbeInterval = 50 * time.Millisecond
+		c.ProbeTimeout = 25 * time.Millisecond
+	}
+	g0 := newTestGossiper(t, "node0", probe)
+	g1 := newTestGossiper(t, "node1", probe)
+
+	if _, ok := g0.RTT("node1"); ok {
+		t.Fatal("expected rtt to be unknown before joining")
+	}
+
+	if _, err := g1.Join(context.Background(), []string{gossiperAddr(t, g0)}); err != nil {
+		t.Fatalf("error joining cluster: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if rtt, ok := g0.RTT("node1"); ok {
+			if rtt <= 0 {
+				t.Fatalf("expected rtt to be positive, but got %v", rtt)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for rtt to be measured")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,109 @@
+package remote
+
+import (
+	"context"
+	"time"
+)
+
+// Subscription is a view of the Gossiper membership with its own
+// events channel, so several consumers, such as rings for different
+// groups of members, can follow the same Gossiper. Every subscription
+// receives all the events, queued independently of the rest of them.
+//
+// Subscriptions share the membership, the ring config, the ring epoch
+// and the round trip times of the Gossiper, but do not publish ring
+// checksums, as the rings of different subscriptions differ from each
+// other.
+type Subscription struct {
+	g        *Gossiper
+	queue    *eventQueue
+	eventsCh chan Event
+	cancel   context.CancelFunc
+	stopped  chan struct{}
+}
+
+// Subscribe creates a new subscription to the Gossiper events, which
+// are delivered from now on until the subscription is closed or the
+// Gossiper is shut down. Events are queued following the events queue
+// config of the Gossiper.
+func (g *Gossiper) Subscribe() *Subscription {
+	ctx, cancel := context.WithCancel(g.ctx)
+
+	s := &Subscription{
+		g:        g,
+		queue:    newEventQueue(g.config.EventQueueSize, g.config.EventQueueOverflow),
+		eventsCh: make(chan Event),
+		cancel:   cancel,
+		stopped:  make(chan struct{}),
+	}
+	g.events.subscribe(s)
+
+	go func() {
+		s.queue.run(s.eventsCh, ctx.Done())
+		g.events.unsubscribe(s)
+		close(s.eventsCh)
+		close(s.stopped)
+	}()
+
+	return s
+}
+
+func (s *Subscription) EventsCh() <-chan Event {
+	return s.eventsCh
+}
+
+// Members returns the list of live members known by the Gossiper.
+func (s *Subscription) Members() []Member {
+	return s.g.Members()
+}
+
+// LocalMember returns the member representing the local node.
+func (s *Subscription) LocalMember() (Member, bool) {
+	return s.g.LocalMember()
+}
+
+// RingConfig returns the ring config replicated by the Gossiper.
+func (s *Subscription) RingConfig() map[string]string {
+	return s.g.RingConfig()
+}
+
+// SetRingConfig sets the value of the given ring config key.
+func (s *Subscription) SetRingConfig(key, value string) error {
+	return s.g.SetRingConfig(key, value)
+}
+
+// DeleteRingConfig deletes the given ring config key.
+func (s *Subscription) DeleteRingConfig(key string) error {
+	return s.g.DeleteRingConfig(key)
+}
+
+// PublishEpoch sets the ring epoch of the local node.
+func (s *Subscription) PublishEpoch(epoch uint64) {
+	s.g.PublishEpoch(epoch)
+}
+
+// Epoch returns the greatest ring epoch published by any member.
+func (s *Subscription) Epoch() uint64 {
+	return s.g.Epoch()
+}
+
+// RTT returns the round trip time to the given member.
+func (s *Subscription) RTT(name string) (time.Duration, bool) {
+	return s.g.RTT(name)
+}
+
+// QueueStats returns the current state of the queue of events
+// pending to be read from EventsCh.
+func (s *Subscription) QueueStats() QueueStats {
+	return s.queue.stats()
+}
+
+// Close stops delivering events and closes the events channel.
+// Close is safe to be called multiple times.
+func (s *Subscription) Close() error {
+	s.queue.close()
+	s.cancel()
+	<-s.stopped
+
+	return nil
+}
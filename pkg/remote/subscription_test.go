@@ -0,0 +1,66 @@
+package remote
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGossiperSubscribe(t *testing.T) {
+	t.Parallel()
+
+	g0 := newTestGossiper(t, "node0")
+	sub0, sub1 := g0.Subscribe(), g0.Subscribe()
+
+	g1 := newTestGossiper(t, "node1")
+	if _, err := g1.Join(context.Background(), []string{gossiperAddr(t, g0)}); err != nil {
+		t.Fatalf("error joining cluster: %v", err)
+	}
+
+	// Every subscription receives the events independently
+	for _, sub := range []*Subscription{sub0, sub1} {
+		waitSubEvent(t, sub, EventJoin, "node1")
+	}
+	waitEvent(t, g0, EventJoin, "node1")
+
+	if err := sub0.Close(); err != nil {
+		t.Fatalf("error closing subscription: %v", err)
+	}
+	if _, ok := <-sub0.EventsCh(); ok {
+		t.Fatal("expected closed subscription events channel to be closed")
+	}
+
+	// Shutting down the Gossiper closes the remaining subscriptions
+	if err := g0.Shutdown(); err != nil {
+		t.Fatalf("error shutting down gossiper: %v", err)
+	}
+	select {
+	case _, ok := <-sub1.EventsCh():
+		if ok {
+			t.Fatal("expected subscription events channel to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for subscription events channel to be closed")
+	}
+}
+
+// waitSubEvent waits until an event of the given type for the given
+// node is received from the subscription, ignoring any other event.
+func waitSubEvent(t *testing.T, sub *Subscription, typ EventType, name string) {
+	t.Helper()
+
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case e, ok := <-sub.EventsCh():
+			if !ok {
+				t.Fatal("events channel closed")
+			}
+			if e.Typ == typ && e.Name == name {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for event %v for node %s", typ, name)
+		}
+	}
+}
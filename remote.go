@@ -0,0 +1,512 @@
+package consistent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ka3de/consistent/pkg/remote"
+)
+
+var (
+	// ErrClosed indicates that the ring has been closed.
+	ErrClosed = errors.New("ring is closed")
+	// ErrRemoteAttached indicates that the ring already has a remote attached.
+	ErrRemoteAttached = errors.New("ring already has a remote attached")
+	// ErrNoRemote indicates that the ring has no remote attached.
+	ErrNoRemote = errors.New("ring has no remote attached")
+	// ErrNameConflict indicates that two different remote nodes are
+	// using the same name, so they are seen as a single ring member.
+	ErrNameConflict = errors.New("remote node name conflict")
+	// ErrNoIdentity indicates that the ring identity of a remote
+	// member could not be derived.
+	ErrNoIdentity = errors.New("remote member has no ring identity")
+	// ErrDivergence indicates that the view of the ring of a remote
+	// member has differed from the local one for too long.
+	ErrDivergence = errors.New("ring view diverges from remote member")
+	// ErrUnknownEvent indicates that a remote event type is not supported.
+	ErrUnknownEvent = errors.New("unknown remote event type")
+)
+
+// AttachRemote attaches the given remote to the ring, which from
+// now on keeps its members in sync with the remote membership until
+// the remote is detached, its events channel is closed or the given
+// context is done. Servers added by hand with Add are not part of the
+// remote membership, so they are kept.
+// If the ring already has a remote attached returns ErrRemoteAttached.
+func (c *Consistent) AttachRemote(ctx context.Context, r remote.Remoter) error {
+	c.remoteMu.Lock()
+	defer c.remoteMu.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+	if c.remote != nil {
+		return ErrRemoteAttached
+	}
+
+	c.remote = r
+	c.startRemote(ctx)
+
+	return nil
+}
+
+// DetachRemote stops applying the events of the attached remote and
+// detaches it from the ring. The ring keeps its current members,
+// although it is reported as stale until a new remote is attached.
+// If the ring has no remote attached returns ErrNoRemote.
+func (c *Consistent) DetachRemote() error {
+	c.remoteMu.Lock()
+	defer c.remoteMu.Unlock()
+
+	if c.remote == nil {
+		return ErrNoRemote
+	}
+
+	c.stopRemote()
+
+	return nil
+}
+
+// Close detaches the remote, if any, and closes the ring so no
+// remote can be attached to it anymore. The ring can still be used
+// for lookups, although it is reported as stale.
+func (c *Consistent) Close() error {
+	c.remoteMu.Lock()
+	defer c.remoteMu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	if c.remote != nil {
+		c.stopRemote()
+	}
+	c.closed = true
+	c.stale.Store(true)
+
+	return nil
+}
+
+// Stale reports whether the ring has stopped tracking the remote
+// membership, either because it was closed, its remote was detached
+// or the remote stopped delivering events.
+func (c *Consistent) Stale() bool {
+	return c.stale.Load()
+}
+
+// startRemote starts the handling of the ring remote.
+// Consistent remote lock must be held before calling this method.
+func (c *Consistent) startRemote(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	c.remoteCancel = cancel
+	c.remoteDone = make(chan struct{})
+	c.stale.Store(false)
+
+	c.handleRemote(ctx, c.remote, c.remoteDone)
+}
+
+// stopRemote stops the handling of the ring remote and waits
+// for it to finish.
+// Consistent remote lock must be held before calling this method.
+func (c *Consistent) stopRemote() {
+	c.remoteCancel()
+	<-c.remoteDone
+
+	c.remote = nil
+	c.remoteCancel = nil
+	c.remoteDone = nil
+	c.stale.Store(true)
+}
+
+// handleRemote keeps the ring members in sync with the remote
+// membership. Events are applied as they are received, and the
+// full membership is reconciled at startup and periodically in
+// order to recover from missed events.
+func (c *Consistent) handleRemote(ctx context.Context, r remote.Remoter, done chan<- struct{}) {
+	eventsCh := r.EventsCh()
+
+	c.inferSelf(r)
+	c.syncEpoch(r)
+	c.syncRingConfig(r)
+	c.reconcile(r)
+
+	// The ring checksum and epoch are published on
+	// startup and every time the ring changes afterwards
+	pub, _ := r.(remote.ChecksumPublisher)
+	rep, _ := r.(remote.EpochReplicator)
+	var published uint64
+	publish := func(force bool) {
+		if (pub == nil && rep == nil) || (!force && c.Epoch() == published) {
+			return
+		}
+		snap := c.Snapshot()
+		published = snap.Epoch
+		if rep != nil {
+			rep.PublishEpoch(snap.Epoch)
+		}
+		if pub != nil {
+			pub.PublishChecksum(snap.Checksum())
+		}
+	}
+	publish(true)
+
+	go func() {
+		defer close(done)
+		defer c.stale.Store(true)
+
+		var tick <-chan time.Time
+		if c.reconcileInterval > 0 {
+			ticker := time.NewTicker(c.reconcileInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for {
+			select {
+			case e, ok := <-eventsCh:
+				if !ok {
+					return
+				}
+				switch e.Typ {
+				case remote.EventRingConfig:
+					c.syncRingConfig(r)
+				case remote.EventEpoch:
+					c.syncEpoch(r)
+				default:
+					c.handleEvent(e)
+				}
+				publish(false)
+			case <-tick:
+				c.syncRingConfig(r)
+				c.reconcile(r)
+				publish(false)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// syncEpoch advances the ring epoch to the greatest one in the
+// cluster, if the given remote replicates the ring epoch.
+func (c *Consistent) syncEpoch(r remote.Remoter) {
+	if rep, ok := r.(remote.EpochReplicator); ok {
+		c.ObserveEpoch(rep.Epoch())
+	}
+}
+
+// handleEvent applies the given remote event to the ring.
+// Membership events are applied idempotently, and events carrying an
+// incarnation are ordered per node so the last incarnation wins and
+// delayed events of a previous incarnation are ignored.
+func (c *Consistent) handleEvent(e remote.Event) {
+	var err error
+	switch e.Typ {
+	case remote.EventJoin, remote.EventUpdate:
+		err = c.upsert(e.Member())
+	case remote.EventLeave:
+		err = c.leave(e.Member())
+	case remote.EventConflict:
+		err = fmt.Errorf("%w: %s is also used by node at %s",
+			ErrNameConflict, e.Name, net.JoinHostPort(e.Addr.String(), strconv.Itoa(int(e.Port))))
+	case remote.EventDivergence:
+		err = fmt.Errorf("%w: %s", ErrDivergence, e.Name)
+	default:
+		err = fmt.Errorf("%w: %v", ErrUnknownEvent, e.Typ)
+	}
+
+	if err != nil {
+		c.handleRcvErr(e, err)
+	}
+}
+
+// upsert registers the given remote member, adding it to or removing
+// it from the ring if it starts or stops being accepted by the ring
+// member filter, or its ring identity changes.
+func (c *Consistent) upsert(m remote.Member) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.observe(m.Name, m.Incarnation, false) {
+		return nil
+	}
+
+	prevID, inRing := c.ringID(m.Name)
+	c.remoteMembers[m.Name] = m
+
+	var (
+		id  string
+		err error
+	)
+	if c.accepts(m) {
+		id, err = c.memberID(m)
+	}
+
+	if inRing && prevID == id {
+		return c.refresh(id, toRingMember(m))
+	}
+	if inRing {
+		c.remove(prevID) //nolint:errcheck
+	}
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		return c.add(id, toRingMember(m))
+	}
+
+	return nil
+}
+
+// leave unregisters the given remote member, removing it
+// from the ring if present.
+func (c *Consistent) leave(m remote.Member) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.observe(m.Name, m.Incarnation, true) {
+		return nil
+	}
+
+	id, inRing := c.ringID(m.Name)
+	delete(c.remoteMembers, m.Name)
+
+	if inRing {
+		return c.remove(id)
+	}
+
+	return nil
+}
+
+// observe records the incarnation of the given node and reports
+// whether an event for it is not older than the last one applied,
+// so it must be applied. Events of the same incarnation are applied
+// in the order they are received, as the remote delivers them in
+// order, so a node which is detected alive again after failing, e.g.
+// once a partition heals, rejoins with the same incarnation.
+// A zero incarnation is always applied.
+// Consistent lock must be held before calling this method.
+func (c *Consistent) observe(name string, inc uint64, left bool) bool {
+	if inc == 0 {
+		return true
+	}
+
+	prev, ok := c.incarnations[name]
+	if ok && inc < prev.n {
+		return false
+	}
+
+	c.incarnations[name] = incarnation{n: inc, left: left, at: time.Now()}
+
+	return true
+}
+
+// pruneIncarnations forgets the incarnations of the nodes
+// which left longer than the tombstone TTL ago.
+// Consistent lock must be held before calling this method.
+func (c *Consistent) pruneIncarnations() {
+	for name, inc := range c.incarnations {
+		if inc.left && time.Since(inc.at) > tombstoneTTL {
+			delete(c.incarnations, name)
+		}
+	}
+}
+
+// accepts reports whether the given remote member
+// should be part of the ring.
+func (c *Consistent) accepts(m remote.Member) bool {
+	return c.filter == nil || c.filter(m)
+}
+
+// memberID returns the ring identity of the given remote member.
+func (c *Consistent) memberID(m remote.Member) (string, error) {
+	id := c.identity(m)
+	if id == "" {
+		return "", fmt.Errorf("%w: %s", ErrNoIdentity, m.Name)
+	}
+
+	return id, nil
+}
+
+// refresh updates the attributes of the given ring member, placing
+// it again in the ring if its weight or drain changed.
+// Consistent lock must be held before calling this method.
+func (c *Consistent) refresh(id string, rm ringMember) error {
+	if prev := c.members[id]; prev.weight == rm.weight && prev.draining == rm.draining {
+		c.members[id] = rm
+		return nil
+	}
+
+	if err := c.remove(id); err != nil {
+		return err
+	}
+	return c.add(id, rm)
+}
+
+// ringID returns the ring identity of the remote member with the
+// given name, and whether the member is currently part of the ring.
+// Consistent lock must be held before calling this method.
+func (c *Consistent) ringID(name string) (string, bool) {
+	m, ok := c.remoteMembers[name]
+	if !ok {
+		return "", false
+	}
+
+	id := c.identity(m)
+	rm, ok := c.members[id]
+
+	return id, ok && rm.name == name
+}
+
+// reconcile applies the minimal set of changes to the ring in
+// order to match the current membership of the given remote.
+// Servers added by hand are kept, as the remote does not know them.
+func (c *Consistent) reconcile(r remote.Remoter) {
+	members := r.Members()
+
+	c.mu.Lock()
+	c.pruneIncarnations()
+	c.remoteMembers = make(map[string]remote.Member, len(members))
+	want := make(map[string]ringMember)
+	for _, m := range members {
+		c.remoteMembers[m.Name] = m
+		if m.Incarnation > 0 {
+			c.incarnations[m.Name] = incarnation{n: m.Incarnation, at: time.Now()}
+		}
+		if !c.accepts(m) {
+			continue
+		}
+		if id, err := c.memberID(m); err == nil {
+			want[id] = toRingMember(m)
+		}
+	}
+
+	// Nodes no longer members left even if their leave was missed,
+	// e.g. dropped by the events queue, so they are pruned in time
+	for name, inc := range c.incarnations {
+		if _, ok := c.remoteMembers[name]; !ok && !inc.left {
+			c.incarnations[name] = incarnation{n: inc.n, left: true, at: time.Now()}
+		}
+	}
+
+	var added, removed []string
+	for id, rm := range want {
+		if _, ok := c.members[id]; ok {
+			c.refresh(id, rm) //nolint:errcheck
+			continue
+		}
+		c.add(id, rm) //nolint:errcheck
+		added = append(added, id)
+	}
+	for id, rm := range c.members {
+		if _, ok := want[id]; !ok && rm.remote {
+			c.remove(id) //nolint:errcheck
+			removed = append(removed, id)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(added) > 0 || len(removed) > 0 {
+		c.logger.Info("reconciled ring with remote membership", "added", added, "removed", removed)
+	}
+}
+
+// RemoteMembers returns all the members known from the remote,
+// sorted by name, including the ones which are not part of the
+// ring because of the ring member filter.
+func (c *Consistent) RemoteMembers() []remote.Member {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	members := make([]remote.Member, 0, len(c.remoteMembers))
+	for _, m := range c.remoteMembers {
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+
+	return members
+}
+
+// tombstoneTTL is the time during which the incarnation of
+// a node which left is kept in order to discard delayed events.
+const tombstoneTTL = 10 * time.Minute
+
+// incarnation represents the last applied incarnation of a node.
+type incarnation struct {
+	n    uint64
+	left bool
+	at   time.Time
+}
+
+// Identity derives the ring identity of a remote member, which is
+// the name the member is known by in the ring. An empty identity
+// prevents the member from being added to the ring.
+type Identity func(remote.Member) string
+
+// IdentityName identifies ring members by their remote node name.
+func IdentityName() Identity {
+	return func(m remote.Member) string {
+		return m.Name
+	}
+}
+
+// IdentityAddr identifies ring members by their addr:port.
+func IdentityAddr() Identity {
+	return func(m remote.Member) string {
+		if m.Addr == nil {
+			return ""
+		}
+		return net.JoinHostPort(m.Addr.String(), strconv.Itoa(int(m.Port)))
+	}
+}
+
+// IdentityTag identifies ring members by the value of the given tag.
+func IdentityTag(key string) Identity {
+	return func(m remote.Member) string {
+		return m.Tags[key]
+	}
+}
+
+func toRingMember(m remote.Member) ringMember {
+	rm := ringMember{name: m.Name, weight: m.Weight, draining: m.Draining, remote: true}
+	if m.Addr != nil {
+		rm.addr = &net.TCPAddr{IP: m.Addr, Port: int(m.Port)}
+	}
+
+	return rm
+}
+
+// MemberFilter decides whether a remote member should be part of
+// the ring, allowing to build rings for different groups of members
+// out of the same remote. As every ring consumes the events of its
+// remote, each ring needs its own view of the remote, such as a
+// remote.Gossiper subscription.
+type MemberFilter func(remote.Member) bool
+
+// MatchTags returns a MemberFilter which accepts the members
+// having all the given tags.
+func MatchTags(tags map[string]string) MemberFilter {
+	return func(m remote.Member) bool {
+		for k, v := range tags {
+			if mv, ok := m.Tags[k]; !ok || mv != v {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func (c *Consistent) handleRcvErr(e remote.Event, err error) {
+	c.logger.Error("error processing remote event", "event", e, "error", err)
+
+	if c.errHandler != nil {
+		c.errHandler(e, err)
+	}
+}
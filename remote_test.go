@@ -0,0 +1,746 @@
+package consistent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ka3de/consistent/pkg/remote"
+)
+
+type mockRemoter struct {
+	mu       sync.Mutex
+	eventsCh chan remote.Event
+	members  []remote.Member
+}
+
+func newMockRemoter(members ...string) *mockRemoter {
+	mr := &mockRemoter{
+		eventsCh: make(chan remote.Event),
+	}
+	mr.setMembers(members...)
+
+	return mr
+}
+
+func (mr *mockRemoter) EventsCh() <-chan remote.Event {
+	return mr.eventsCh
+}
+
+func (mr *mockRemoter) Members() []remote.Member {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return mr.members
+}
+
+func (mr *mockRemoter) setMembers(members ...string) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.members = nil
+	for _, m := range members {
+		mr.members = append(mr.members, remote.Member{Name: m})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		initial     []string
+		added       []string
+		members     []string
+		wantMembers []string
+	}{
+		{
+			name:        "should add missing members",
+			members:     []string{"srv0", "srv1"},
+			wantMembers: []string{"srv0", "srv1"},
+		},
+		{
+			name:        "should remove stale members",
+			initial:     []string{"srv0", "srv1", "srv2"},
+			members:     []string{"srv1"},
+			wantMembers: []string{"srv1"},
+		},
+		{
+			name:        "should add and remove members",
+			initial:     []string{"srv0", "srv1"},
+			members:     []string{"srv1", "srv2"},
+			wantMembers: []string{"srv1", "srv2"},
+		},
+		{
+			name:        "should keep servers added by hand",
+			initial:     []string{"srv0"},
+			added:       []string{"static"},
+			members:     []string{"srv1"},
+			wantMembers: []string{"srv1", "static"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mr := newMockRemoter(tc.initial...)
+			c := NewConsistent()
+			c.reconcile(mr)
+			for _, srv := range tc.added {
+				if err := c.Add(srv); err != nil {
+					t.Fatalf("error adding srv: %v", err)
+				}
+			}
+
+			mr.setMembers(tc.members...)
+			c.reconcile(mr)
+
+			checkC(t, c, len(tc.wantMembers), len(tc.wantMembers)*defNReplicas, len(tc.wantMembers)*defNReplicas)
+			for _, m := range tc.wantMembers {
+				if _, ok := c.members[m]; !ok {
+					t.Fatalf("expected member %s to be present in the ring", m)
+				}
+			}
+		})
+	}
+}
+
+func TestAttachRemote(t *testing.T) {
+	t.Parallel()
+
+	c := NewConsistent()
+	mr := newMockRemoter("srv0")
+
+	if err := c.AttachRemote(context.Background(), mr); err != nil {
+		t.Fatalf("error attaching remote: %v", err)
+	}
+	if err := c.AttachRemote(context.Background(), mr); !errors.Is(err, ErrRemoteAttached) {
+		t.Fatalf("unexpected error. want: %v but got: %v", ErrRemoteAttached, err)
+	}
+	if c.Stale() {
+		t.Fatal("expected ring not to be stale")
+	}
+
+	mr.eventsCh <- remote.Event{Typ: remote.EventJoin, Name: "srv1"}
+	waitFor(t, func() bool {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		return c.count() == 2
+	})
+
+	if err := c.DetachRemote(); err != nil {
+		t.Fatalf("error detaching remote: %v", err)
+	}
+	if err := c.DetachRemote(); !errors.Is(err, ErrNoRemote) {
+		t.Fatalf("unexpected error. want: %v but got: %v", ErrNoRemote, err)
+	}
+	if !c.Stale() {
+		t.Fatal("expected ring to be stale")
+	}
+
+	select {
+	case mr.eventsCh <- remote.Event{Typ: remote.EventLeave, Name: "srv1"}:
+		t.Fatal("expected detached ring not to receive events")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAttachRemoteContextDone(t *testing.T) {
+	t.Parallel()
+
+	c := NewConsistent()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if err := c.AttachRemote(ctx, newMockRemoter()); err != nil {
+		t.Fatalf("error attaching remote: %v", err)
+	}
+
+	cancel()
+	waitFor(t, c.Stale)
+}
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+
+	mr := newMockRemoter("srv0")
+	c := NewConsistent(WithRemote(mr))
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("error closing ring: %v", err)
+	}
+	if !c.Stale() {
+		t.Fatal("expected ring to be stale")
+	}
+	if err := c.AttachRemote(context.Background(), mr); !errors.Is(err, ErrClosed) {
+		t.Fatalf("unexpected error. want: %v but got: %v", ErrClosed, err)
+	}
+	if srv, err := c.Get("any"); err != nil || srv != "srv0" {
+		t.Fatalf("expected closed ring to keep its members, but got srv: %s err: %v", srv, err)
+	}
+}
+
+// waitFor waits until the given condition is true or fails the
+// test after a timeout.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for !cond() {
+		select {
+		case <-timeout:
+			t.Fatal("timeout waiting for condition")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestHandleEventErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		event   remote.Event
+		wantErr error
+	}{
+		{
+			name:    "should report name conflict",
+			event:   remote.Event{Typ: remote.EventConflict, Name: "srv0"},
+			wantErr: ErrNameConflict,
+		},
+		{
+			name:    "should report ring divergence",
+			event:   remote.Event{Typ: remote.EventDivergence, Name: "srv0"},
+			wantErr: ErrDivergence,
+		},
+		{
+			name:    "should report unknown event type",
+			event:   remote.Event{Typ: 100, Name: "srv0"},
+			wantErr: ErrUnknownEvent,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotErr error
+			c := NewConsistent(
+				WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+				WithErrorHandler(func(_ remote.Event, err error) { gotErr = err }),
+			)
+			c.handleEvent(tc.event)
+
+			if !errors.Is(gotErr, tc.wantErr) {
+				t.Fatalf("unexpected error. want: %v but got: %v", tc.wantErr, gotErr)
+			}
+		})
+	}
+}
+
+func TestHandleEvent(t *testing.T) {
+	t.Parallel()
+
+	storage := map[string]string{"role": "storage"}
+	proxy := map[string]string{"role": "proxy"}
+
+	testCases := []struct {
+		name              string
+		opts              []opt
+		events            []remote.Event
+		wantMembers       []string
+		wantRemoteMembers int
+	}{
+		{
+			name: "should add joined members",
+			events: []remote.Event{
+				{Typ: remote.EventJoin, Name: "srv0"},
+				{Typ: remote.EventJoin, Name: "srv1"},
+			},
+			wantMembers:       []string{"srv0", "srv1"},
+			wantRemoteMembers: 2,
+		},
+		{
+			name: "should remove left members",
+			events: []remote.Event{
+				{Typ: remote.EventJoin, Name: "srv0"},
+				{Typ: remote.EventJoin, Name: "srv1"},
+				{Typ: remote.EventLeave, Name: "srv0"},
+			},
+			wantMembers:       []string{"srv1"},
+			wantRemoteMembers: 1,
+		},
+		{
+			name: "should only add members matching the filter",
+			opts: []opt{WithMemberFilter(MatchTags(storage))},
+			events: []remote.Event{
+				{Typ: remote.EventJoin, Name: "srv0", Tags: storage},
+				{Typ: remote.EventJoin, Name: "srv1", Tags: proxy},
+				{Typ: remote.EventJoin, Name: "srv2"},
+			},
+			wantMembers:       []string{"srv0"},
+			wantRemoteMembers: 3,
+		},
+		{
+			name: "should add and remove updated members",
+			opts: []opt{WithMemberFilter(MatchTags(storage))},
+			events: []remote.Event{
+				{Typ: remote.EventJoin, Name: "srv0", Tags: storage},
+				{Typ: remote.EventJoin, Name: "srv1", Tags: proxy},
+				{Typ: remote.EventUpdate, Name: "srv0", Tags: proxy},
+				{Typ: remote.EventUpdate, Name: "srv1", Tags: storage},
+			},
+			wantMembers:       []string{"srv1"},
+			wantRemoteMembers: 2,
+		},
+		{
+			name: "should remove filtered member which left",
+			opts: []opt{WithMemberFilter(MatchTags(storage))},
+			events: []remote.Event{
+				{Typ: remote.EventJoin, Name: "srv0", Tags: storage},
+				{Typ: remote.EventJoin, Name: "srv1", Tags: proxy},
+				{Typ: remote.EventLeave, Name: "srv1", Tags: proxy},
+			},
+			wantMembers:       []string{"srv0"},
+			wantRemoteMembers: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := NewConsistent(tc.opts...)
+			for _, e := range tc.events {
+				c.handleEvent(e)
+			}
+
+			if members := c.Members(); !reflect.DeepEqual(members, tc.wantMembers) {
+				t.Fatalf("expected members to be %v, but got %v", tc.wantMembers, members)
+			}
+			if n := len(c.RemoteMembers()); n != tc.wantRemoteMembers {
+				t.Fatalf("expected %d remote members, but got %d", tc.wantRemoteMembers, n)
+			}
+		})
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	t.Parallel()
+
+	m := remote.Member{
+		Name: "node0",
+		Addr: net.ParseIP("10.0.0.1"),
+		Port: 7946,
+		Tags: map[string]string{"id": "storage-0"},
+	}
+
+	testCases := []struct {
+		name        string
+		identity    Identity
+		wantMembers []string
+	}{
+		{
+			name:        "should identify members by name",
+			identity:    IdentityName(),
+			wantMembers: []string{"node0"},
+		},
+		{
+			name:        "should identify members by address",
+			identity:    IdentityAddr(),
+			wantMembers: []string{"10.0.0.1:7946"},
+		},
+		{
+			name:        "should identify members by tag",
+			identity:    IdentityTag("id"),
+			wantMembers: []string{"storage-0"},
+		},
+		{
+			name:        "should not add members without identity",
+			identity:    IdentityTag("missing"),
+			wantMembers: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := NewConsistent(WithIdentity(tc.identity))
+			c.handleEvent(remote.Event{Typ: remote.EventJoin, Name: m.Name, Addr: m.Addr, Port: m.Port, Tags: m.Tags})
+
+			if members := c.Members(); !reflect.DeepEqual(members, tc.wantMembers) {
+				t.Fatalf("expected members to be %v, but got %v", tc.wantMembers, members)
+			}
+			if len(tc.wantMembers) == 0 {
+				return
+			}
+
+			addr, err := c.GetAddr("any")
+			if err != nil {
+				t.Fatalf("error getting address: %v", err)
+			}
+			if want := "10.0.0.1:7946"; addr.String() != want {
+				t.Fatalf("expected address to be %s, but got %s", want, addr)
+			}
+
+			c.handleEvent(remote.Event{Typ: remote.EventLeave, Name: m.Name, Addr: m.Addr, Port: m.Port, Tags: m.Tags})
+			if members := c.Members(); len(members) != 0 {
+				t.Fatalf("expected members to be empty, but got %v", members)
+			}
+		})
+	}
+}
+
+func TestHandleEventIncarnation(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		events      []remote.Event
+		wantMembers []string
+	}{
+		{
+			name: "should keep restarted member on delayed leave",
+			events: []remote.Event{
+				{Typ: remote.EventJoin, Name: "srv0", Incarnation: 1},
+				{Typ: remote.EventJoin, Name: "srv0", Incarnation: 2},
+				{Typ: remote.EventLeave, Name: "srv0", Incarnation: 1},
+			},
+			wantMembers: []string{"srv0"},
+		},
+		{
+			name: "should add member rejoining with the same incarnation",
+			events: []remote.Event{
+				{Typ: remote.EventJoin, Name: "srv0", Incarnation: 1},
+				{Typ: remote.EventLeave, Name: "srv0", Incarnation: 1},
+				{Typ: remote.EventJoin, Name: "srv0", Incarnation: 1},
+			},
+			wantMembers: []string{"srv0"},
+		},
+		{
+			name: "should add restarted member after leave",
+			events: []remote.Event{
+				{Typ: remote.EventJoin, Name: "srv0", Incarnation: 1},
+				{Typ: remote.EventLeave, Name: "srv0", Incarnation: 1},
+				{Typ: remote.EventJoin, Name: "srv0", Incarnation: 2},
+			},
+			wantMembers: []string{"srv0"},
+		},
+		{
+			name: "should remove member on newer leave",
+			events: []remote.Event{
+				{Typ: remote.EventJoin, Name: "srv0", Incarnation: 1},
+				{Typ: remote.EventLeave, Name: "srv0", Incarnation: 2},
+			},
+			wantMembers: []string{},
+		},
+		{
+			name: "should apply duplicated events idempotently",
+			events: []remote.Event{
+				{Typ: remote.EventJoin, Name: "srv0"},
+				{Typ: remote.EventJoin, Name: "srv0"},
+				{Typ: remote.EventLeave, Name: "srv1"},
+			},
+			wantMembers: []string{"srv0"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := NewConsistent()
+			for _, e := range tc.events {
+				c.handleEvent(e)
+			}
+
+			if members := c.Members(); !reflect.DeepEqual(members, tc.wantMembers) {
+				t.Fatalf("expected members to be %v, but got %v", tc.wantMembers, members)
+			}
+		})
+	}
+}
+
+func TestReconcileIncarnations(t *testing.T) {
+	t.Parallel()
+
+	c := NewConsistent()
+	mr := newMockRemoter()
+
+	// The leave of srv0 is missed, so only reconcile removes it
+	c.handleEvent(remote.Event{Typ: remote.EventJoin, Name: "srv0", Incarnation: 1})
+	c.reconcile(mr)
+
+	c.mu.Lock()
+	inc, ok := c.incarnations["srv0"]
+	c.mu.Unlock()
+	if !ok || !inc.left {
+		t.Fatalf("expected srv0 incarnation to be marked as left, but got %+v", inc)
+	}
+
+	// Once the tombstone expires the incarnation is forgotten
+	c.mu.Lock()
+	c.incarnations["srv0"] = incarnation{n: inc.n, left: true, at: inc.at.Add(-2 * tombstoneTTL)}
+	c.mu.Unlock()
+	c.reconcile(mr)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.incarnations["srv0"]; ok {
+		t.Fatal("expected srv0 incarnation to be pruned")
+	}
+}
+
+type checksumMockRemoter struct {
+	*mockRemoter
+	sums chan uint64
+}
+
+func (mr *checksumMockRemoter) PublishChecksum(sum uint64) {
+	mr.sums <- sum
+}
+
+func TestPublishChecksum(t *testing.T) {
+	t.Parallel()
+
+	mr := &checksumMockRemoter{
+		mockRemoter: newMockRemoter("srv0", "srv1"),
+		sums:        make(chan uint64, 10),
+	}
+	c := NewConsistent(WithRemote(mr))
+	t.Cleanup(func() { c.Close() }) //nolint:errcheck
+
+	waitSum := func() uint64 {
+		t.Helper()
+		select {
+		case sum := <-mr.sums:
+			return sum
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for checksum")
+		}
+		return 0
+	}
+
+	if got, want := waitSum(), c.Snapshot().Checksum(); got != want {
+		t.Fatalf("expected initial checksum to be %d, but got %d", want, got)
+	}
+
+	// Events which do not change the ring must not publish
+	mr.eventsCh <- remote.Event{Typ: remote.EventUpdate, Name: "srv0"}
+	mr.eventsCh <- remote.Event{Typ: remote.EventJoin, Name: "srv2"}
+
+	if got, want := waitSum(), c.Snapshot().Checksum(); got != want {
+		t.Fatalf("expected checksum to be %d, but got %d", want, got)
+	}
+	select {
+	case sum := <-mr.sums:
+		t.Fatalf("unexpected checksum %d published", sum)
+	default:
+	}
+}
+
+type rttMockRemoter struct {
+	*mockRemoter
+	rtts map[string]time.Duration
+}
+
+func (mr *rttMockRemoter) RTT(name string) (time.Duration, bool) {
+	rtt, ok := mr.rtts[name]
+	return rtt, ok
+}
+
+func TestGetNearest(t *testing.T) {
+	t.Parallel()
+
+	const unknown = -1
+
+	testCases := []struct {
+		name string
+		// rtts of the srvs returned by GetN, by their position
+		rtts []time.Duration
+		// position of the local node in the srvs returned by GetN
+		self      int
+		wantOrder []int
+	}{
+		{
+			name:      "should keep preference order without rtts",
+			self:      unknown,
+			wantOrder: []int{0, 1, 2},
+		},
+		{
+			name:      "should order by rtt",
+			rtts:      []time.Duration{30, 20, 10},
+			self:      unknown,
+			wantOrder: []int{2, 1, 0},
+		},
+		{
+			name:      "should keep unknown rtts last in preference order",
+			rtts:      []time.Duration{unknown, 20, unknown},
+			self:      unknown,
+			wantOrder: []int{1, 0, 2},
+		},
+		{
+			name:      "should put local node first",
+			rtts:      []time.Duration{30, 20, 10},
+			self:      1,
+			wantOrder: []int{1, 2, 0},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mr := &rttMockRemoter{mockRemoter: newMockRemoter("srv0", "srv1", "srv2", "srv3")}
+			c := NewConsistent(WithRemote(mr))
+			t.Cleanup(func() { c.Close() }) //nolint:errcheck
+
+			srvs, err := c.GetN("key", 3)
+			if err != nil {
+				t.Fatalf("error getting srvs: %v", err)
+			}
+
+			if tc.rtts != nil {
+				mr.rtts = make(map[string]time.Duration)
+				for i, rtt := range tc.rtts {
+					if rtt != unknown {
+						mr.rtts[srvs[i]] = rtt
+					}
+				}
+			}
+			if tc.self != unknown {
+				c.self = srvs[tc.self]
+			}
+
+			got, err := c.GetNearest("key", 3)
+			if err != nil {
+				t.Fatalf("error getting nearest srvs: %v", err)
+			}
+
+			want := make([]string, len(tc.wantOrder))
+			for i, idx := range tc.wantOrder {
+				want[i] = srvs[idx]
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("expected nearest srvs to be %v, but got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestEpochReplication(t *testing.T) {
+	t.Parallel()
+
+	var (
+		rings []*Consistent
+		seed  string
+	)
+	for i := 0; i < 2; i++ {
+		g, err := remote.NewGossiper(remote.GossiperConfig{
+			NodeName:   fmt.Sprintf("node%d", i),
+			Network:    remote.GossiperNetworkLocal,
+			Divergence: remote.DivergenceConfig{Interval: 20 * time.Millisecond},
+		})
+		if err != nil {
+			t.Fatalf("error creating gossiper: %v", err)
+		}
+		if err := g.Start(); err != nil {
+			t.Fatalf("error starting gossiper: %v", err)
+		}
+
+		c := NewConsistent(WithRemote(g))
+		t.Cleanup(func() {
+			c.Close() //nolint:errcheck
+			g.Close() //nolint:errcheck
+		})
+		rings = append(rings, c)
+
+		if seed == "" {
+			// The first node observed a far greater epoch, e.g.
+			// attached to a request, before the second one joins
+			c.ObserveEpoch(100)
+			local, _ := g.LocalMember()
+			seed = net.JoinHostPort(local.Addr.String(), strconv.Itoa(int(local.Port)))
+		} else if _, err := g.Join(context.Background(), []string{seed}); err != nil {
+			t.Fatalf("error joining cluster: %v", err)
+		}
+	}
+
+	// The join bumps the epoch of the first node, which the
+	// second one advances to, so its own changes come after
+	waitFor(t, func() bool { return rings[0].Epoch() > 100 })
+	waitFor(t, func() bool { return rings[1].Epoch() >= rings[0].Epoch() })
+}
+
+func TestFilteredRingsSubscription(t *testing.T) {
+	t.Parallel()
+
+	roles := []string{"a", "a", "b"}
+
+	var (
+		rings = make(map[string]*Consistent)
+		seed  string
+	)
+	for i, role := range roles {
+		g, err := remote.NewGossiper(remote.GossiperConfig{
+			NodeName: fmt.Sprintf("node%d", i),
+			Network:  remote.GossiperNetworkLocal,
+			Tags:     map[string]string{"role": role},
+		})
+		if err != nil {
+			t.Fatalf("error creating gossiper: %v", err)
+		}
+		if err := g.Start(); err != nil {
+			t.Fatalf("error starting gossiper: %v", err)
+		}
+		t.Cleanup(func() { g.Close() }) //nolint:errcheck
+
+		if seed != "" {
+			if _, err := g.Join(context.Background(), []string{seed}); err != nil {
+				t.Fatalf("error joining cluster: %v", err)
+			}
+			continue
+		}
+
+		// Every ring of the first node follows its own subscription,
+		// so both of them see all the membership events
+		local, _ := g.LocalMember()
+		seed = net.JoinHostPort(local.Addr.String(), strconv.Itoa(int(local.Port)))
+		for _, r := range []string{"a", "b"} {
+			sub := g.Subscribe()
+			c := NewConsistent(
+				WithRemote(sub),
+				WithMemberFilter(MatchTags(map[string]string{"role": r})),
+			)
+			t.Cleanup(func() {
+				c.Close()   //nolint:errcheck
+				sub.Close() //nolint:errcheck
+			})
+			rings[r] = c
+		}
+	}
+
+	want := map[string][]string{
+		"a": {"node0", "node1"},
+		"b": {"node2"},
+	}
+	for r, members := range want {
+		c := rings[r]
+		waitFor(t, func() bool { return reflect.DeepEqual(c.Members(), members) })
+	}
+}
@@ -0,0 +1,217 @@
+package consistent
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ka3de/consistent/pkg/remote"
+)
+
+// Ring config keys, which are prefixes followed
+// by the server or key name they apply to.
+const (
+	ringConfigWeight   = "weight/"
+	ringConfigDrain    = "drain/"
+	ringConfigOverride = "override/"
+)
+
+// ErrInvalidWeight indicates that a server weight is not valid.
+var ErrInvalidWeight = fmt.Errorf("server weight must be a number between 0 and %d", remote.MaxWeight)
+
+// SetWeight sets the weight of the given server, which scales its
+// number of replicas in the ring, so a server with weight 2 owns
+// about twice the keys of a server with the default weight of 1.
+// A weight of 0 removes the server from lookups, as if drained,
+// and the weight can not be greater than remote.MaxWeight.
+// The weight takes precedence over the one announced by the remote
+// member, if any, until it is reset with ResetWeight.
+// If the ring remote replicates the ring config the weight is
+// applied to the whole cluster.
+func (c *Consistent) SetWeight(srv string, weight float64) error {
+	if !validWeight(weight) {
+		return fmt.Errorf("%w: %v", ErrInvalidWeight, weight)
+	}
+
+	return c.setRingConfig(ringConfigWeight+srv, strconv.FormatFloat(weight, 'g', -1, 64))
+}
+
+// ResetWeight removes the weight set for the given server, which
+// goes back to the weight announced by its remote member, if any,
+// or the default weight of 1.
+func (c *Consistent) ResetWeight(srv string) error {
+	return c.setRingConfig(ringConfigWeight+srv, "")
+}
+
+// SetDraining sets whether the given server is draining. Draining
+// servers are kept as members, but are not returned by lookups.
+// If the ring remote replicates the ring config the drain is
+// applied to the whole cluster.
+func (c *Consistent) SetDraining(srv string, draining bool) error {
+	var value string
+	if draining {
+		value = "true"
+	}
+
+	return c.setRingConfig(ringConfigDrain+srv, value)
+}
+
+// SetOverride pins the given key to the given server, which owns it
+// regardless of the ring hashes while it is a member not drained.
+// An empty server removes the override.
+// If the ring remote replicates the ring config the override is
+// applied to the whole cluster.
+func (c *Consistent) SetOverride(key, srv string) error {
+	return c.setRingConfig(ringConfigOverride+key, srv)
+}
+
+// setRingConfig sets the value of the given ring config key,
+// deleting it if the value is empty. The change is written
+// through the ring remote if it replicates the ring config.
+func (c *Consistent) setRingConfig(key, value string) error {
+	c.remoteMu.Lock()
+	r := c.remote
+	c.remoteMu.Unlock()
+
+	if rep, ok := r.(remote.RingConfigReplicator); ok {
+		var err error
+		if value == "" {
+			err = rep.DeleteRingConfig(key)
+		} else {
+			err = rep.SetRingConfig(key, value)
+		}
+		if err != nil {
+			return fmt.Errorf("error replicating ring config: %w", err)
+		}
+
+		c.applyRingConfig(rep.RingConfig())
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	config := make(map[string]string, len(c.ringConfig)+1)
+	for k, v := range c.ringConfig {
+		config[k] = v
+	}
+	if value == "" {
+		delete(config, key)
+	} else {
+		config[key] = value
+	}
+	c.applyRingConfigLocked(config)
+
+	return nil
+}
+
+// syncRingConfig applies the ring config of the given
+// remote, if it replicates the ring config.
+func (c *Consistent) syncRingConfig(r remote.Remoter) {
+	if rep, ok := r.(remote.RingConfigReplicator); ok {
+		c.applyRingConfig(rep.RingConfig())
+	}
+}
+
+func (c *Consistent) applyRingConfig(config map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.applyRingConfigLocked(config)
+}
+
+// applyRingConfigLocked applies the given ring config, rebuilding
+// the ring if the weights or drains of the servers changed. Keys
+// which are unknown or have invalid values are ignored.
+// Consistent lock must be held before calling this method.
+func (c *Consistent) applyRingConfigLocked(config map[string]string) {
+	weights := make(map[string]float64)
+	drains := make(map[string]bool)
+	overrides := make(map[string]string)
+
+	for k, v := range config {
+		switch {
+		case strings.HasPrefix(k, ringConfigWeight):
+			w, err := strconv.ParseFloat(v, 64)
+			if err != nil || !validWeight(w) {
+				c.logger.Warn("ignoring invalid ring config", "key", k, "value", v)
+				continue
+			}
+			weights[strings.TrimPrefix(k, ringConfigWeight)] = w
+		case strings.HasPrefix(k, ringConfigDrain):
+			d, err := strconv.ParseBool(v)
+			if err != nil {
+				c.logger.Warn("ignoring invalid ring config", "key", k, "value", v)
+				continue
+			}
+			drains[strings.TrimPrefix(k, ringConfigDrain)] = d
+		case strings.HasPrefix(k, ringConfigOverride):
+			overrides[strings.TrimPrefix(k, ringConfigOverride)] = v
+		}
+	}
+
+	c.ringConfig = config
+
+	placementChanged := !maps.Equal(weights, c.weights) || !maps.Equal(drains, c.drains)
+	overridesChanged := !maps.Equal(overrides, c.overrides)
+	c.weights = weights
+	c.drains = drains
+	c.overrides = overrides
+
+	if placementChanged {
+		c.rebuild()
+	}
+	if placementChanged || overridesChanged {
+		c.epoch++
+	}
+}
+
+// validWeight reports whether the given weight
+// is a number between 0 and remote.MaxWeight.
+func validWeight(w float64) bool {
+	return w >= 0 && w <= remote.MaxWeight
+}
+
+// vnodes returns the number of replicas of the given server in
+// the ring, based on its drain and weight, being the weight set
+// in the ring config preferred over the one of the member. The
+// server is drained if either the ring config or its member are.
+// Consistent lock must be held before calling this method.
+func (c *Consistent) vnodes(srv string) int {
+	if c.drains[srv] || c.members[srv].draining {
+		return 0
+	}
+
+	w, ok := c.weights[srv]
+	if !ok {
+		w = c.members[srv].weight
+	}
+	if w == 0 && !ok {
+		return c.nReplicas
+	}
+	// Member weights are announced by the remote,
+	// so they are bounded here instead of rejected
+	w = min(w, remote.MaxWeight)
+
+	n := int(math.Round(w * float64(c.nReplicas)))
+	if n == 0 && w > 0 {
+		n = 1
+	}
+
+	return n
+}
+
+// rebuild recomputes the ring hashes of all the members.
+// Consistent lock must be held before calling this method.
+func (c *Consistent) rebuild() {
+	c.ring = make(map[Hash]string)
+	for srv := range c.members {
+		for i := 0; i < c.vnodes(srv); i++ {
+			c.ring[c.hasher.Hash(c.srvKey(srv, i))] = srv
+		}
+	}
+
+	c.updateHashes()
+}
@@ -0,0 +1,322 @@
+package consistent
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"testing"
+
+	"github.com/ka3de/consistent/pkg/remote"
+)
+
+func TestSetWeight(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		weight     float64
+		wantVnodes int
+		wantErr    error
+	}{
+		{
+			name:       "should keep replicas with default weight",
+			weight:     1,
+			wantVnodes: defNReplicas,
+		},
+		{
+			name:       "should scale replicas by weight",
+			weight:     2,
+			wantVnodes: 2 * defNReplicas,
+		},
+		{
+			name:       "should keep at least one replica",
+			weight:     0.001,
+			wantVnodes: 1,
+		},
+		{
+			name:       "should remove srv with zero weight",
+			weight:     0,
+			wantVnodes: 0,
+		},
+		{
+			name:       "should return error for negative weight",
+			weight:     -1,
+			wantVnodes: defNReplicas,
+			wantErr:    ErrInvalidWeight,
+		},
+		{
+			name:       "should return error for weight above maximum",
+			weight:     1e7,
+			wantVnodes: defNReplicas,
+			wantErr:    ErrInvalidWeight,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := newTestC(t, 2)
+			epoch := c.Epoch()
+
+			err := c.SetWeight("srv0", tc.weight)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("unexpected error. want: %v but got: %v", tc.wantErr, err)
+			}
+
+			if vnodes := len(c.Snapshot().Members["srv0"]); vnodes != tc.wantVnodes {
+				t.Fatalf("expected srv0 vnodes to be %d, but got %d", tc.wantVnodes, vnodes)
+			}
+			checkC(t, c, 2, tc.wantVnodes+defNReplicas, tc.wantVnodes+defNReplicas)
+
+			if changed := c.Epoch() != epoch; changed != (err == nil) {
+				t.Fatalf("expected epoch change to be %v", !changed)
+			}
+
+			if err := c.ResetWeight("srv0"); err != nil {
+				t.Fatalf("error resetting weight: %v", err)
+			}
+			checkC(t, c, 2, 2*defNReplicas, 2*defNReplicas)
+		})
+	}
+}
+
+func TestSetDraining(t *testing.T) {
+	t.Parallel()
+
+	c := newTestC(t, 3)
+
+	if err := c.SetDraining("srv0", true); err != nil {
+		t.Fatalf("error draining srv: %v", err)
+	}
+	checkC(t, c, 3, 2*defNReplicas, 2*defNReplicas)
+	for i := 0; i < 100; i++ {
+		if srv, _ := c.Get(fmt.Sprintf("key%d", i)); srv == "srv0" {
+			t.Fatal("expected drained srv not to own any key")
+		}
+	}
+
+	for _, srv := range []string{"srv1", "srv2"} {
+		if err := c.SetDraining(srv, true); err != nil {
+			t.Fatalf("error draining srv: %v", err)
+		}
+	}
+	if _, err := c.Get("any"); !errors.Is(err, ErrNoSrvs) {
+		t.Fatalf("unexpected error. want: %v but got: %v", ErrNoSrvs, err)
+	}
+
+	if err := c.SetDraining("srv1", false); err != nil {
+		t.Fatalf("error undraining srv: %v", err)
+	}
+	if srv, err := c.Get("any"); err != nil || srv != "srv1" {
+		t.Fatalf("expected srv to be srv1, but got %q (err: %v)", srv, err)
+	}
+}
+
+func TestSetOverride(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		srv     string
+		drained bool
+		want    func(c *Consistent) string
+	}{
+		{
+			name: "should return override srv",
+			srv:  "srv1",
+			want: func(*Consistent) string { return "srv1" },
+		},
+		{
+			name: "should ignore override to non member",
+			srv:  "srv9",
+			want: func(c *Consistent) string { return c.ring[c.hashes[c.search(c.hasher.Hash("key"))]] },
+		},
+		{
+			name:    "should ignore override to drained srv",
+			srv:     "srv1",
+			drained: true,
+			want:    func(c *Consistent) string { return c.ring[c.hashes[c.search(c.hasher.Hash("key"))]] },
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := newTestC(t, 3)
+			if err := c.SetOverride("key", tc.srv); err != nil {
+				t.Fatalf("error setting override: %v", err)
+			}
+			if err := c.SetDraining("srv1", tc.drained); err != nil {
+				t.Fatalf("error draining srv: %v", err)
+			}
+
+			srv, err := c.Get("key")
+			if err != nil {
+				t.Fatalf("error getting srv: %v", err)
+			}
+			if want := tc.want(c); srv != want {
+				t.Fatalf("expected srv to be %q, but got %q", want, srv)
+			}
+		})
+	}
+}
+
+type ringConfigMockRemoter struct {
+	*mockRemoter
+
+	configMu sync.Mutex
+	config   map[string]string
+}
+
+func (mr *ringConfigMockRemoter) RingConfig() map[string]string {
+	mr.configMu.Lock()
+	defer mr.configMu.Unlock()
+
+	config := make(map[string]string, len(mr.config))
+	for k, v := range mr.config {
+		config[k] = v
+	}
+	return config
+}
+
+func (mr *ringConfigMockRemoter) SetRingConfig(key, value string) error {
+	mr.configMu.Lock()
+	defer mr.configMu.Unlock()
+
+	mr.config[key] = value
+	return nil
+}
+
+func (mr *ringConfigMockRemoter) DeleteRingConfig(key string) error {
+	mr.configMu.Lock()
+	defer mr.configMu.Unlock()
+
+	delete(mr.config, key)
+	return nil
+}
+
+func TestRingConfigRemote(t *testing.T) {
+	t.Parallel()
+
+	mr := &ringConfigMockRemoter{
+		mockRemoter: newMockRemoter("srv0", "srv1"),
+		config:      map[string]string{ringConfigDrain + "srv0": "true"},
+	}
+	c := NewConsistent(WithRemote(mr))
+	t.Cleanup(func() { c.Close() }) //nolint:errcheck
+
+	// Initial config is applied on attach
+	if _, ok := c.Snapshot().Members["srv0"]; ok {
+		t.Fatal("expected srv0 to be drained")
+	}
+
+	// Local changes are written through the remote
+	if err := c.SetWeight("srv1", 2); err != nil {
+		t.Fatalf("error setting weight: %v", err)
+	}
+	if v := mr.RingConfig()[ringConfigWeight+"srv1"]; v != "2" {
+		t.Fatalf("expected remote weight to be %q, but got %q", "2", v)
+	}
+
+	// Remote changes are applied on notification
+	mr.DeleteRingConfig(ringConfigDrain + "srv0") //nolint:errcheck
+	mr.eventsCh <- remote.Event{Typ: remote.EventRingConfig}
+	waitFor(t, func() bool {
+		_, ok := c.Snapshot().Members["srv0"]
+		return ok
+	})
+
+	// Remote weights above the maximum are ignored
+	mr.SetRingConfig(ringConfigWeight+"srv0", "1e7") //nolint:errcheck
+	mr.eventsCh <- remote.Event{Typ: remote.EventRingConfig}
+	waitFor(t, func() bool {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		return maps.Equal(c.ringConfig, mr.RingConfig())
+	})
+	if vnodes := len(c.Snapshot().Members["srv0"]); vnodes != defNReplicas {
+		t.Fatalf("expected srv0 vnodes to be %d, but got %d", defNReplicas, vnodes)
+	}
+}
+
+func TestMemberWeight(t *testing.T) {
+	t.Parallel()
+
+	c := NewConsistent()
+	vnodes := func() int { return len(c.Snapshot().Members["srv0"]) }
+
+	if err := c.upsert(remote.Member{Name: "srv0", Weight: 2}); err != nil {
+		t.Fatalf("error upserting member: %v", err)
+	}
+	if n := vnodes(); n != 2*defNReplicas {
+		t.Fatalf("expected vnodes to be %d, but got %d", 2*defNReplicas, n)
+	}
+
+	// Weight changes place the member again
+	if err := c.upsert(remote.Member{Name: "srv0", Weight: 0.5}); err != nil {
+		t.Fatalf("error upserting member: %v", err)
+	}
+	if n := vnodes(); n != defNReplicas/2 {
+		t.Fatalf("expected vnodes to be %d, but got %d", defNReplicas/2, n)
+	}
+
+	// Ring config weight takes precedence
+	if err := c.SetWeight("srv0", 1); err != nil {
+		t.Fatalf("error setting weight: %v", err)
+	}
+	if n := vnodes(); n != defNReplicas {
+		t.Fatalf("expected vnodes to be %d, but got %d", defNReplicas, n)
+	}
+	if err := c.ResetWeight("srv0"); err != nil {
+		t.Fatalf("error resetting weight: %v", err)
+	}
+	if n := vnodes(); n != defNReplicas/2 {
+		t.Fatalf("expected vnodes to be %d, but got %d", defNReplicas/2, n)
+	}
+
+	// Member weights are bounded by the maximum weight
+	if err := c.upsert(remote.Member{Name: "srv0", Weight: 1e7}); err != nil {
+		t.Fatalf("error upserting member: %v", err)
+	}
+	if n := vnodes(); n != remote.MaxWeight*defNReplicas {
+		t.Fatalf("expected vnodes to be %d, but got %d", remote.MaxWeight*defNReplicas, n)
+	}
+
+	if err := c.leave(remote.Member{Name: "srv0"}); err != nil {
+		t.Fatalf("error removing member: %v", err)
+	}
+	checkC(t, c, 0, 0, 0)
+}
+
+func TestMemberDraining(t *testing.T) {
+	t.Parallel()
+
+	c := NewConsistent()
+
+	for _, m := range []remote.Member{{Name: "srv0", Draining: true}, {Name: "srv1"}} {
+		if err := c.upsert(m); err != nil {
+			t.Fatalf("error upserting member: %v", err)
+		}
+	}
+	checkC(t, c, 2, defNReplicas, defNReplicas)
+
+	epoch := c.Epoch()
+	if err := c.upsert(remote.Member{Name: "srv0"}); err != nil {
+		t.Fatalf("error upserting member: %v", err)
+	}
+	checkC(t, c, 2, 2*defNReplicas, 2*defNReplicas)
+	if c.Epoch() == epoch {
+		t.Fatal("expected epoch to change once member is active")
+	}
+
+	// Ring config drain applies to active members
+	if err := c.SetDraining("srv0", true); err != nil {
+		t.Fatalf("error draining srv: %v", err)
+	}
+	checkC(t, c, 2, defNReplicas, defNReplicas)
+}
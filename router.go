@@ -0,0 +1,189 @@
+package consistent
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ka3de/consistent/pkg/remote"
+)
+
+// ErrInvalidMessage indicates that a routed message could not be decoded.
+var ErrInvalidMessage = errors.New("invalid routed message")
+
+// Message is a message routed by key to the ring members owning it.
+type Message struct {
+	// From is the remote node name of the sender.
+	From    string
+	Key     string
+	Payload []byte
+}
+
+// Router routes messages by key to the ring members owning the key,
+// using the Gossiper transport, so small messages such as cache
+// invalidations can be exchanged between members without an extra
+// server. Only one Router can be used per Gossiper.
+type Router struct {
+	c          *Consistent
+	g          *remote.Gossiper
+	bestEffort bool
+	handler    atomic.Pointer[func(Message)]
+}
+
+type routerOpt func(*Router)
+
+// WithBestEffort makes the Router send messages over UDP, which is
+// cheaper but can lose messages, and limits their size to a single
+// packet, returning remote.ErrMessageTooLarge for larger ones. By
+// default messages are sent over TCP.
+func WithBestEffort() routerOpt {
+	return func(r *Router) {
+		r.bestEffort = true
+	}
+}
+
+// NewRouter creates a new Router for the given ring, whose members
+// must be the nodes of the given Gossiper, and registers it as the
+// Gossiper message handler.
+func NewRouter(c *Consistent, g *remote.Gossiper, opts ...routerOpt) *Router {
+	r := &Router{c: c, g: g}
+
+	for _, o := range opts {
+		o(r)
+	}
+
+	g.SetMessageHandler(r.receive)
+
+	return r
+}
+
+// Handle sets the handler of the messages routed to the local node,
+// replacing the previous one. The handler is invoked synchronously
+// by the Gossiper transport, so it must not block.
+func (r *Router) Handle(h func(Message)) {
+	r.handler.Store(&h)
+}
+
+// SendToOwner sends the given payload to the owner of the given key.
+// If the ring has no servers returns ErrNoSrvs.
+func (r *Router) SendToOwner(key string, payload []byte) error {
+	return r.SendToReplicas(key, 1, payload)
+}
+
+// SendToReplicas sends the given payload to the first n servers for
+// the given key, as returned by GetN. The payload is sent to all of
+// them even if some fail, returning the errors of the failed ones.
+// If the ring has no servers returns ErrNoSrvs.
+func (r *Router) SendToReplicas(key string, n int, payload []byte) error {
+	nodes, err := r.c.nodeNames(key, n)
+	if err != nil {
+		return err
+	}
+
+	local, _ := r.g.LocalMember()
+	msg := Message{From: local.Name, Key: key, Payload: payload}
+
+	var errs []error
+	for _, node := range nodes {
+		if node == local.Name {
+			r.deliver(msg)
+			continue
+		}
+		if err := r.send(node, encodeMessage(msg)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *Router) send(node string, buf []byte) error {
+	if r.bestEffort {
+		return r.g.SendBestEffort(node, buf)
+	}
+	return r.g.SendReliable(node, buf)
+}
+
+// receive decodes and delivers a message received from another node.
+func (r *Router) receive(buf []byte) {
+	msg, err := decodeMessage(buf)
+	if err != nil {
+		r.c.logger.Warn("dropping routed message", "error", err)
+		return
+	}
+
+	r.deliver(msg)
+}
+
+func (r *Router) deliver(msg Message) {
+	if h := r.handler.Load(); h != nil && *h != nil {
+		(*h)(msg)
+	}
+}
+
+// nodeNames returns the remote node names of up to n
+// servers for the given key, in preference order.
+// If the ring has no servers returns ErrNoSrvs.
+func (c *Consistent) nodeNames(key string, n int) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.hashes) == 0 {
+		return nil, ErrNoSrvs
+	}
+
+	srvs := c.getN(key, n)
+	names := make([]string, len(srvs))
+	for i, srv := range srvs {
+		names[i] = c.members[srv].name
+	}
+
+	return names, nil
+}
+
+// encodeMessage encodes the given message as the length prefixed
+// sender and key, followed by the payload.
+func encodeMessage(msg Message) []byte {
+	buf := make([]byte, 0, 2*binary.MaxVarintLen16+len(msg.From)+len(msg.Key)+len(msg.Payload))
+	buf = binary.AppendUvarint(buf, uint64(len(msg.From)))
+	buf = append(buf, msg.From...)
+	buf = binary.AppendUvarint(buf, uint64(len(msg.Key)))
+	buf = append(buf, msg.Key...)
+
+	return append(buf, msg.Payload...)
+}
+
+func decodeMessage(buf []byte) (Message, error) {
+	var msg Message
+
+	from, buf, err := decodeString(buf)
+	if err != nil {
+		return msg, fmt.Errorf("%w: sender: %v", ErrInvalidMessage, err)
+	}
+	key, buf, err := decodeString(buf)
+	if err != nil {
+		return msg, fmt.Errorf("%w: key: %v", ErrInvalidMessage, err)
+	}
+
+	msg.From = from
+	msg.Key = key
+	msg.Payload = buf
+
+	return msg, nil
+}
+
+// decodeString decodes a length prefixed string,
+// returning the rest of the buffer.
+func decodeString(buf []byte) (string, []byte, error) {
+	l, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return "", nil, errors.New("invalid length")
+	}
+	buf = buf[n:]
+	if uint64(len(buf)) < l {
+		return "", nil, fmt.Errorf("length %d exceeds %d bytes left", l, len(buf))
+	}
+
+	return string(buf[:l]), buf[l:], nil
+}
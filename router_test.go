@@ -0,0 +1,148 @@
+package consistent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ka3de/consistent/pkg/remote"
+)
+
+func TestMessageEncoding(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		msg     Message
+		buf     []byte
+		wantErr error
+	}{
+		{
+			name: "should decode encoded message",
+			msg:  Message{From: "node0", Key: "key", Payload: []byte("payload")},
+		},
+		{
+			name: "should decode message without payload",
+			msg:  Message{From: "node0", Key: "key", Payload: []byte{}},
+		},
+		{
+			name:    "should return error on empty message",
+			buf:     []byte{},
+			wantErr: ErrInvalidMessage,
+		},
+		{
+			name:    "should return error on truncated message",
+			buf:     []byte{10, 'n'},
+			wantErr: ErrInvalidMessage,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := tc.buf
+			if buf == nil {
+				buf = encodeMessage(tc.msg)
+			}
+
+			msg, err := decodeMessage(buf)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("unexpected error. want: %v but got: %v", tc.wantErr, err)
+			}
+			if err == nil && !reflect.DeepEqual(msg, tc.msg) {
+				t.Fatalf("expected message to be %v, but got %v", tc.msg, msg)
+			}
+		})
+	}
+}
+
+func TestRouter(t *testing.T) {
+	t.Parallel()
+
+	const nNodes = 3
+
+	var (
+		routers  []*Router
+		received []chan Message
+		seed     string
+	)
+	for i := 0; i < nNodes; i++ {
+		g, err := remote.NewGossiper(remote.GossiperConfig{
+			NodeName: fmt.Sprintf("node%d", i),
+			Network:  remote.GossiperNetworkLocal,
+		})
+		if err != nil {
+			t.Fatalf("error creating gossiper: %v", err)
+		}
+		if err := g.Start(); err != nil {
+			t.Fatalf("error starting gossiper: %v", err)
+		}
+
+		c := NewConsistent(WithRemote(g))
+		t.Cleanup(func() {
+			c.Close() //nolint:errcheck
+			g.Close() //nolint:errcheck
+		})
+
+		local, _ := g.LocalMember()
+		if seed == "" {
+			seed = net.JoinHostPort(local.Addr.String(), strconv.Itoa(int(local.Port)))
+		} else if _, err := g.Join(context.Background(), []string{seed}); err != nil {
+			t.Fatalf("error joining cluster: %v", err)
+		}
+
+		ch := make(chan Message, nNodes)
+		r := NewRouter(c, g)
+		r.Handle(func(msg Message) { ch <- msg })
+
+		routers = append(routers, r)
+		received = append(received, ch)
+	}
+
+	for _, r := range routers {
+		waitFor(t, func() bool { return len(r.c.Members()) == nNodes })
+	}
+
+	waitMsg := func(t *testing.T, node int, want Message) {
+		t.Helper()
+		select {
+		case msg := <-received[node]:
+			if !reflect.DeepEqual(msg, want) {
+				t.Fatalf("expected message to be %v, but got %v", want, msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for message on node%d", node)
+		}
+	}
+	nodeIdx := func(name string) int {
+		i, _ := strconv.Atoi(name[len("node"):])
+		return i
+	}
+
+	// Owner receives messages from every node, including itself
+	for i, r := range routers {
+		key := fmt.Sprintf("key%d", i)
+		owner, _ := r.c.Get(key)
+
+		if err := r.SendToOwner(key, []byte("invalidate")); err != nil {
+			t.Fatalf("error sending to owner: %v", err)
+		}
+		waitMsg(t, nodeIdx(owner), Message{From: fmt.Sprintf("node%d", i), Key: key, Payload: []byte("invalidate")})
+	}
+
+	// Replicas receive the message once each
+	replicas, _ := routers[0].c.GetN("key", 2)
+	if err := routers[0].SendToReplicas("key", 2, []byte("replicate")); err != nil {
+		t.Fatalf("error sending to replicas: %v", err)
+	}
+	for _, srv := range replicas {
+		waitMsg(t, nodeIdx(srv), Message{From: "node0", Key: "key", Payload: []byte("replicate")})
+	}
+}